@@ -0,0 +1,336 @@
+package kovacs
+
+import "fmt"
+
+// A Node is an expression term decoded into an inspectable form.  The
+// operands of allof, anyof and not are held in Children, and every other
+// argument is held in Args as a string, int64, bool, []interface{} or
+// map[string]interface{}
+type Node struct {
+	Term     string
+	Children []*Node
+	Args     []interface{}
+}
+
+// ToAST decodes expr into a Node tree
+func ToAST(expr Expression) (*Node, error) {
+	if err := checkTerm(expr); err != nil {
+		return nil, err
+	}
+
+	return toNode(expr.(exprSlice)), nil
+}
+
+func toNode(sl exprSlice) *Node {
+	n := &Node{Term: string(sl[0].(exprString))}
+
+	switch n.Term {
+	case "allof", "anyof", "not":
+		for _, e := range sl[1:] {
+			n.Children = append(n.Children, toNode(e.(exprSlice)))
+		}
+	default:
+		for _, e := range sl[1:] {
+			n.Args = append(n.Args, toValue(e))
+		}
+	}
+
+	return n
+}
+
+func toValue(e Expression) interface{} {
+	switch e := e.(type) {
+	case exprString:
+		return string(e)
+	case exprInt:
+		return int64(e)
+	case exprBool:
+		return bool(e)
+	case exprSlice:
+		vals := make([]interface{}, len(e))
+		for i := range e {
+			vals[i] = toValue(e[i])
+		}
+
+		return vals
+	case exprObject:
+		vals := make(map[string]interface{}, len(e))
+		for k := range e {
+			vals[k] = toValue(e[k])
+		}
+
+		return vals
+	}
+
+	return nil
+}
+
+// Expression converts the node back into an Expression.  An error is
+// returned if an argument is not one of the types listed on Node
+func (n *Node) Expression() (Expression, error) {
+	sl := exprSlice{exprString(n.Term)}
+
+	for _, c := range n.Children {
+		e, err := c.Expression()
+
+		if err != nil {
+			return nil, err
+		}
+
+		sl = append(sl, e)
+	}
+
+	for _, a := range n.Args {
+		e, err := fromValue(a)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", n.Term, err)
+		}
+
+		sl = append(sl, e)
+	}
+
+	return sl, nil
+}
+
+func fromValue(v interface{}) (Expression, error) {
+	switch v := v.(type) {
+	case string:
+		return exprString(v), nil
+	case int:
+		return exprInt(v), nil
+	case int64:
+		return exprInt(v), nil
+	case bool:
+		return exprBool(v), nil
+	case []string:
+		return fromStringSlice(v), nil
+	case []interface{}:
+		sl := make(exprSlice, len(v))
+		for i := range v {
+			e, err := fromValue(v[i])
+
+			if err != nil {
+				return nil, err
+			}
+
+			sl[i] = e
+		}
+
+		return sl, nil
+	case map[string]interface{}:
+		obj := make(exprObject, len(v))
+		for k := range v {
+			e, err := fromValue(v[k])
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj[k] = e
+		}
+
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("unsupported argument %v of type %T", v, v)
+}
+
+func (n *Node) String() string {
+	expr, err := n.Expression()
+
+	if err != nil {
+		return "<invalid: " + err.Error() + ">"
+	}
+
+	return expr.String()
+}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with w, followed by a call of w.Visit(nil)
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order
+func Walk(v Visitor, node *Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	for _, c := range node.Children {
+		Walk(v, c)
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(node *Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f for each node.  If f returns true, Inspect invokes f for each of the
+// children of node, followed by a call of f(nil)
+func Inspect(node *Node, f func(*Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite replaces each node in the tree rooted at node, bottom up, with
+// the result of f
+func Rewrite(node *Node, f func(*Node) *Node) *Node {
+	for i, c := range node.Children {
+		node.Children[i] = Rewrite(c, f)
+	}
+
+	return f(node)
+}
+
+// Simplify returns an equivalent expression with nested allof and anyof
+// terms flattened, double negations removed, true and false folded away
+// and the suffix terms of an anyof merged into a single suffix term.  The
+// merged form needs the suffix-set capability
+func Simplify(expr Expression) (Expression, error) {
+	n, err := ToAST(expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Rewrite(n, simplify).Expression()
+}
+
+func simplify(n *Node) *Node {
+	switch n.Term {
+	case "not":
+		if len(n.Children) != 1 {
+			return n
+		}
+
+		switch c := n.Children[0]; c.Term {
+		case "not":
+			if len(c.Children) == 1 {
+				return c.Children[0]
+			}
+		case "true":
+			return &Node{Term: "false"}
+		case "false":
+			return &Node{Term: "true"}
+		}
+	case "allof", "anyof":
+		// in an allof, true is the identity and false absorbs everything.
+		// in an anyof it is the other way around
+		identity, absorb := "true", "false"
+		if n.Term == "anyof" {
+			identity, absorb = absorb, identity
+		}
+
+		var children []*Node
+
+		for _, c := range n.Children {
+			switch c.Term {
+			case identity:
+				continue
+			case absorb:
+				return &Node{Term: absorb}
+			case n.Term:
+				children = append(children, c.Children...)
+			default:
+				children = append(children, c)
+			}
+		}
+
+		if n.Term == "anyof" {
+			children = mergeSuffixes(children)
+		}
+
+		switch len(children) {
+		case 0:
+			return &Node{Term: identity}
+		case 1:
+			return children[0]
+		}
+
+		return &Node{Term: n.Term, Children: children}
+	}
+
+	return n
+}
+
+// mergeSuffixes replaces the suffix terms in the operands of an anyof with
+// a single suffix term, in the position of the first
+func mergeSuffixes(children []*Node) []*Node {
+	var (
+		merged   []*Node
+		suffixes []string
+		seen     = map[string]bool{}
+		first    = -1
+	)
+
+	for _, c := range children {
+		s, ok := suffixArgs(c)
+
+		if !ok {
+			merged = append(merged, c)
+			continue
+		}
+
+		if first < 0 {
+			first = len(merged)
+			merged = append(merged, nil)
+		}
+
+		for _, suffix := range s {
+			if !seen[suffix] {
+				seen[suffix] = true
+				suffixes = append(suffixes, suffix)
+			}
+		}
+	}
+
+	if first < 0 {
+		return children
+	}
+
+	if len(suffixes) == 1 {
+		merged[first] = &Node{Term: "suffix", Args: []interface{}{suffixes[0]}}
+	} else {
+		merged[first] = &Node{Term: "suffix", Args: []interface{}{stringParams(suffixes...)}}
+	}
+
+	return merged
+}
+
+func suffixArgs(n *Node) ([]string, bool) {
+	if n.Term != "suffix" || len(n.Args) != 1 {
+		return nil, false
+	}
+
+	switch a := n.Args[0].(type) {
+	case string:
+		return []string{a}, true
+	case []interface{}:
+		strs := make([]string, len(a))
+
+		for i := range a {
+			s, ok := a[i].(string)
+
+			if !ok {
+				return nil, false
+			}
+
+			strs[i] = s
+		}
+
+		return strs, true
+	}
+
+	return nil, false
+}
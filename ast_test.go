@@ -0,0 +1,77 @@
+package kovacs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToAST(t *testing.T) {
+	expr := AllOf(Suffix("go"), Not(DirnameDepth(CaseSensitive, "vendor", Ge, 1)))
+	n, err := ToAST(expr)
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, n.Term == "allof" && len(n.Children) == 2, "unexpected node %+v", n)
+	assert(t, n.Children[0].Args[0] == "go", "unexpected suffix args %v", n.Children[0].Args)
+	back, err := n.Expression()
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, reflect.DeepEqual(back, expr), "expected %s, found %s", expr, back)
+
+	_, err = ToAST(exprString("suffix"))
+	assert(t, err != nil, "expected an error for a bare string")
+}
+
+func TestNodeExpression_InvalidArg(t *testing.T) {
+	n := &Node{Term: "size", Args: []interface{}{"gt", 1.5}}
+
+	_, err := n.Expression()
+	assert(t, err != nil, "expected an error for a float argument")
+
+	_, err = (&Node{Term: "not", Children: []*Node{n}}).Expression()
+	assert(t, err != nil, "expected an error from a child node")
+}
+
+func TestInspect(t *testing.T) {
+	n, _ := ToAST(AnyOf(Suffix("go"), AllOf(Exists(), Not(Empty()))))
+
+	var terms []string
+	Inspect(n, func(n *Node) bool {
+		if n != nil {
+			terms = append(terms, n.Term)
+		}
+
+		return n == nil || n.Term != "not"
+	})
+
+	expected := "anyof,suffix,allof,exists,not"
+	assert(t, strings.Join(terms, ",") == expected, "expected %s, found %v", expected, terms)
+}
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected Expression
+	}{
+		{AllOf(AllOf(Exists(), Empty()), AllOf(Type(TypeRegularFile))), AllOf(Exists(), Empty(), Type(TypeRegularFile))},
+		{AnyOf(Exists(), AnyOf(Empty(), Type(TypeDirectory))), AnyOf(Exists(), Empty(), Type(TypeDirectory))},
+		{Not(Not(Exists())), Exists()},
+		{Not(Not(Not(Exists()))), Not(Exists())},
+		{AllOf(True(), Exists()), Exists()},
+		{AllOf(False(), Exists()), False()},
+		{AnyOf(True(), Exists()), True()},
+		{AnyOf(False(), Exists()), Exists()},
+		{Not(True()), False()},
+		{AllOf(Not(False()), True()), True()},
+		{AnyOf(), False()},
+		{AnyOf(Suffix("go"), Exists(), Suffix("mod", "go")), AnyOf(Suffix("go", "mod"), Exists())},
+		{AnyOf(Suffix("go"), AnyOf(Suffix("mod"))), Suffix("go", "mod")},
+		{AllOf(Suffix("go"), Suffix("mod")), AllOf(Suffix("go"), Suffix("mod"))},
+	}
+
+	for _, test := range tests {
+		expr, err := Simplify(test.expr)
+
+		assert(t, err == nil, "unexpected err: %s", err)
+		assert(t, reflect.DeepEqual(expr, test.expected), "%s: expected %s, found %s", test.expr, test.expected, expr)
+	}
+}
@@ -0,0 +1,125 @@
+package kovacs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// optionVersions are the releases that introduced query options which the
+// server does not advertise as capabilities.  They are reported by
+// HasCapability under the option name when the server is at least that
+// version
+var optionVersions = map[string]string{
+	"case_sensitive":             "4.1.0",
+	"lock_timeout":               "4.6.0",
+	"fail_if_no_saved_state":     "4.9.0",
+	"always_include_directories": "2020.08.03.00",
+}
+
+// A CapabilityError is returned when a request depends on a capability that
+// the connected watchman server does not support
+// https://facebook.github.io/watchman/docs/capabilities.html
+type CapabilityError struct {
+	Capability string
+}
+
+func (e *CapabilityError) Error() string {
+	return "watchman server does not support capability " + e.Capability
+}
+
+// HasCapability reports whether the connected server supports the named
+// capability.  The server's capabilities are fetched once and cached.  The
+// query options in optionVersions are derived from the server version
+func (c *Client) HasCapability(name string) (bool, error) {
+	caps, err := c.capabilities()
+
+	if err != nil {
+		return false, err
+	}
+
+	return caps[name], nil
+}
+
+func (c *Client) capabilities() (map[string]bool, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+
+	if c.caps != nil {
+		return c.caps, nil
+	}
+
+	list, err := c.ListCapabilities()
+
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := c.Version()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.caps = make(map[string]bool, len(list)+len(optionVersions))
+	for _, name := range list {
+		c.caps[name] = true
+	}
+
+	for name, since := range optionVersions {
+		if compareVersions(version, since) >= 0 {
+			c.caps[name] = true
+		}
+	}
+
+	return c.caps, nil
+}
+
+// compareVersions compares two dotted version numbers, returning -1, 0 or 1.
+// Both the semantic (4.9.0) and date based (2021.05.10.00) schemes are
+// ordered correctly, as date based versions have a larger major number
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// requireCapabilities returns a *CapabilityError for the first capability
+// in names that the server does not support
+func (c *Client) requireCapabilities(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	caps, err := c.capabilities()
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if !caps[name] {
+			return &CapabilityError{Capability: name}
+		}
+	}
+
+	return nil
+}
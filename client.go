@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"sync"
 	"sync/atomic"
 )
 
@@ -36,6 +37,7 @@ func socketLoc() (string, error) {
 
 type req struct {
 	dest   interface{}
+	stream *fileStream
 	respCh chan error
 	cmd    []interface{}
 }
@@ -87,6 +89,45 @@ type Client struct {
 	closeCh     chan chan error
 	logHandler  func(string)
 	subHandlers map[string]func(*SubscriptionEvent)
+
+	capsMu sync.Mutex
+	caps   map[string]bool
+
+	watchMu sync.Mutex
+	watches map[string]map[string]int // root -> holder -> acquisitions
+
+	subsMu sync.Mutex
+	subs   map[string]bool // live subscriptions, keyed by subKey
+}
+
+func subKey(root, name string) string {
+	return root + "\x00" + name
+}
+
+func (c *Client) addSub(root, name string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	if c.subs == nil {
+		c.subs = map[string]bool{}
+	}
+
+	c.subs[subKey(root, name)] = true
+}
+
+func (c *Client) removeSub(root, name string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	delete(c.subs, subKey(root, name))
+}
+
+// hasSubs reports whether any subscription may still deliver events
+func (c *Client) hasSubs() bool {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	return len(c.subs) > 0
 }
 
 // Connect initializes the connection the watchman server.  It assumes that
@@ -126,6 +167,7 @@ func (c *Client) listen() {
 		dec    = json.NewDecoder(logReader("response", c.conn))
 		respCh = make(chan *resp)
 		closed int32
+		slot   streamSlot
 	)
 
 	// read json values off the socket and send back to main
@@ -136,17 +178,44 @@ func (c *Client) listen() {
 				return
 			}
 
-			var r resp
+			r, err := readResp(dec, &slot)
 
-			if err := dec.Decode(&r); err != nil {
+			if err != nil {
 				panic("JSON decoding error " + err.Error())
 			}
 
-			respCh <- &r
+			respCh <- r
 		}
 	}()
 
-	var curReq *req
+	// the server answers requests in order and a reply can only be tied to
+	// its request, and its stream, while no other request is in flight, so
+	// requests are queued and sent one at a time
+	var (
+		curReq  *req
+		pending []*req
+	)
+
+	// send the next queued request, failing any that can't be written
+	sendNext := func() {
+		for curReq == nil && len(pending) > 0 {
+			req := pending[0]
+			pending = pending[1:]
+
+			if req.stream != nil {
+				req.stream.direct = !c.hasSubs()
+				slot.set(req.stream)
+			}
+
+			if err := enc.Encode(req.cmd); err != nil {
+				slot.set(nil)
+				req.respCh <- err
+				continue
+			}
+
+			curReq = req
+		}
+	}
 
 OUTER:
 	for {
@@ -178,26 +247,19 @@ OUTER:
 			req := curReq
 			curReq = nil
 
-			if resp.Error != nil {
+			switch {
+			case resp.Error != nil:
 				req.respCh <- errors.New(*resp.Error)
-				continue
-			}
-
-			if req.dest != nil {
-				if err := json.Unmarshal(resp.Raw, req.dest); err != nil {
-					req.respCh <- err
-					continue
-				}
+			case req.dest != nil:
+				req.respCh <- json.Unmarshal(resp.Raw, req.dest)
+			default:
+				req.respCh <- nil
 			}
 
-			req.respCh <- nil
+			sendNext()
 		case req := <-c.reqCh:
-			if err := enc.Encode(req.cmd); err != nil {
-				req.respCh <- err
-				continue
-			}
-
-			curReq = req
+			pending = append(pending, req)
+			sendNext()
 		case closeCh := <-c.closeCh:
 			atomic.StoreInt32(&closed, 1)
 			closeCh <- c.conn.Close()
@@ -229,3 +291,22 @@ func (c *Client) send(dest interface{}, args ...interface{}) error {
 
 	return nil
 }
+
+// sendStream is like send, but the elements of the response's files array
+// are passed to fn one at a time instead of being unmarshaled into dest
+func (c *Client) sendStream(dest interface{}, fn func(json.RawMessage) error, args ...interface{}) error {
+	req := req{
+		dest:   dest,
+		stream: &fileStream{fn: fn},
+		respCh: make(chan error),
+		cmd:    args,
+	}
+
+	c.reqCh <- &req
+
+	if err := <-req.respCh; err != nil {
+		return err
+	}
+
+	return req.stream.err
+}
@@ -0,0 +1,198 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Clock is a parsed watchman clock.  Server clocks have the form
+// c:<start>:<pid>:<root>:<ticks>, named cursors have the form n:<name> and
+// scm aware clocks additionally carry the merge base they were produced from
+// https://facebook.github.io/watchman/docs/clockspec.html
+type Clock struct {
+	StartTime  int64
+	Pid        int
+	RootNumber int
+	Ticks      uint32
+
+	// Cursor is the name of a named cursor.  When set, the other
+	// server clock fields are unused
+	Cursor string
+
+	Mergebase     string
+	MergebaseWith string
+}
+
+// ErrZeroClock is returned when a Clock that holds no clock is used to find
+// changes.  A zero Clock is not a valid clockspec
+var ErrZeroClock = errors.New("zero clock")
+
+// ParseClock parses a clock string as returned from the server
+func ParseClock(s string) (Clock, error) {
+	var c Clock
+
+	if strings.HasPrefix(s, "n:") && len(s) > 2 {
+		c.Cursor = s[2:]
+		return c, nil
+	}
+
+	parts := strings.Split(s, ":")
+
+	if parts[0] != "c" || (len(parts) != 3 && len(parts) != 5) {
+		return c, fmt.Errorf("invalid clock %q", s)
+	}
+
+	var (
+		nums = parts[1:]
+		err  error
+	)
+
+	// older servers emit c:<pid>:<ticks>
+	if len(nums) == 4 {
+		if c.StartTime, err = strconv.ParseInt(nums[0], 10, 64); err != nil {
+			return c, fmt.Errorf("invalid clock %q: %s", s, err)
+		}
+
+		if c.RootNumber, err = strconv.Atoi(nums[2]); err != nil {
+			return c, fmt.Errorf("invalid clock %q: %s", s, err)
+		}
+
+		nums = []string{nums[1], nums[3]}
+	}
+
+	if c.Pid, err = strconv.Atoi(nums[0]); err != nil {
+		return c, fmt.Errorf("invalid clock %q: %s", s, err)
+	}
+
+	ticks, err := strconv.ParseUint(nums[1], 10, 32)
+
+	if err != nil {
+		return c, fmt.Errorf("invalid clock %q: %s", s, err)
+	}
+
+	c.Ticks = uint32(ticks)
+
+	return c, nil
+}
+
+// IsZero reports whether c holds neither a server clock nor a named cursor
+func (c Clock) IsZero() bool {
+	return c.StartTime == 0 && c.Pid == 0 && c.RootNumber == 0 && c.Ticks == 0 && c.Cursor == ""
+}
+
+// IsCursor reports whether c is a named cursor
+func (c Clock) IsCursor() bool {
+	return c.Cursor != ""
+}
+
+// IsSCM reports whether c is an scm aware clock
+func (c Clock) IsSCM() bool {
+	return c.MergebaseWith != ""
+}
+
+// SameInstance reports whether c and o were produced by the same server
+// process for the same root.  If they were not, the server has restarted or
+// the root was re-watched and ticks cannot be compared
+func (c Clock) SameInstance(o Clock) bool {
+	if c.IsCursor() || o.IsCursor() {
+		return false
+	}
+
+	return c.StartTime == o.StartTime && c.Pid == o.Pid && c.RootNumber == o.RootNumber
+}
+
+// Compare returns -1, 0 or 1 as c is before, equal to or after o.  An error
+// is returned if the clocks do not come from the same instance
+func (c Clock) Compare(o Clock) (int, error) {
+	if !c.SameInstance(o) {
+		return 0, fmt.Errorf("clocks %s and %s are from different instances", c, o)
+	}
+
+	switch {
+	case c.Ticks < o.Ticks:
+		return -1, nil
+	case c.Ticks > o.Ticks:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// String returns the clock in the string form used by the server.  The scm
+// merge base is not included
+func (c Clock) String() string {
+	if c.IsCursor() {
+		return "n:" + c.Cursor
+	}
+
+	if c.StartTime == 0 && c.RootNumber == 0 {
+		return fmt.Sprintf("c:%d:%d", c.Pid, c.Ticks)
+	}
+
+	return fmt.Sprintf("c:%d:%d:%d:%d", c.StartTime, c.Pid, c.RootNumber, c.Ticks)
+}
+
+// SCMSince returns an SCMSince that continues from an scm aware clock, as
+// returned in an SCMQueryResult.  The clock is left out when c is zero
+func (c Clock) SCMSince() SCMSince {
+	s := SCMSince{
+		Mergebase:     c.Mergebase,
+		MergebaseWith: c.MergebaseWith,
+	}
+
+	if !c.IsZero() {
+		s.Clock = c.String()
+	}
+
+	return s
+}
+
+func (c Clock) MarshalJSON() ([]byte, error) {
+	if c.IsSCM() {
+		return json.Marshal(c.SCMSince())
+	}
+
+	return json.Marshal(c.String())
+}
+
+func (c *Clock) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err == nil {
+		clock, err := ParseClock(s)
+
+		if err != nil {
+			return err
+		}
+
+		*c = clock
+		return nil
+	}
+
+	var sc struct {
+		Clock string `json:"clock"`
+		SCM   struct {
+			Mergebase     string `json:"mergebase"`
+			MergebaseWith string `json:"mergebase-with"`
+		} `json:"scm"`
+	}
+
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return err
+	}
+
+	clock, err := ParseClock(sc.Clock)
+
+	if err != nil {
+		return err
+	}
+
+	clock.Mergebase = sc.SCM.Mergebase
+	clock.MergebaseWith = sc.SCM.MergebaseWith
+	*c = clock
+
+	return nil
+}
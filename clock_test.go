@@ -0,0 +1,80 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseClock(t *testing.T) {
+	for _, s := range []string{"c:1500000000:123:1:42", "c:123:42", "n:foo"} {
+		c, err := ParseClock(s)
+
+		assert(t, err == nil, "unexpected err parsing %s: %s", s, err)
+		assert(t, c.String() == s, "expected %s, found %s", s, c)
+	}
+
+	for _, s := range []string{"", "c:1:2:3", "n:", "c:a:b", "x:1:2"} {
+		_, err := ParseClock(s)
+		assert(t, err != nil, "expected error parsing %q", s)
+	}
+}
+
+func TestClockCompare(t *testing.T) {
+	a, _ := ParseClock("c:1500000000:123:1:42")
+	b, _ := ParseClock("c:1500000000:123:1:50")
+	restarted, _ := ParseClock("c:1500000100:456:1:2")
+
+	assert(t, a.SameInstance(b), "expected same instance")
+	assert(t, !a.SameInstance(restarted), "expected different instance")
+
+	cmp, err := a.Compare(b)
+	assert(t, err == nil && cmp == -1, "expected -1, found %d (%v)", cmp, err)
+
+	_, err = a.Compare(restarted)
+	assert(t, err != nil, "expected compare error")
+}
+
+func TestClockJSON(t *testing.T) {
+	var c Clock
+	err := json.Unmarshal([]byte(`{"clock": "c:1:2:3:4", "scm": {"mergebase": "abc", "mergebase-with": "master"}}`), &c)
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, c.Ticks == 4 && c.Mergebase == "abc" && c.MergebaseWith == "master", "unexpected clock %+v", c)
+
+	b, _ := json.Marshal(c)
+	expected := `{"clock":"c:1:2:3:4","scm":{"mergebase":"abc","mergebase-with":"master"}}`
+	assert(t, string(b) == expected, "expected %s, found %s", expected, b)
+}
+
+func TestClockZero(t *testing.T) {
+	assert(t, Clock{}.IsZero(), "zero clock not reported as zero")
+	assert(t, !mustParseClock("n:foo").IsZero(), "cursor reported as zero")
+
+	s := Clock{MergebaseWith: "master"}.SCMSince()
+	assert(t, s.Clock == "" && s.MergebaseWith == "master", "unexpected scm since %+v", s)
+
+	_, err := SinceClock(Clock{})
+	assert(t, err == ErrZeroClock, "expected ErrZeroClock, found %v", err)
+
+	r := &Root{c: NewClient(nil), Path: "/src/project"}
+	_, _, err = r.Since(Clock{})
+	assert(t, err == ErrZeroClock, "expected ErrZeroClock, found %v", err)
+}
+
+func mustParseClock(s string) Clock {
+	c, err := ParseClock(s)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func mustExpr(e Expression, err error) Expression {
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
@@ -1,23 +1,28 @@
 package kovacs
 
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
 // Clock returns the watchman server clock time at the specified root
 // for more info, see https://facebook.github.io/watchman/docs/cmd/clock.html
-func (c *Client) Clock(root string) (string, error) {
+func (c *Client) Clock(root string) (Clock, error) {
 	var s struct {
-		Clock string
+		Clock Clock
 	}
 
 	if err := c.send(&s, "clock", root); err != nil {
-		return "", err
+		return Clock{}, err
 	}
 
 	return s.Clock, nil
 }
 
 // https://facebook.github.io/watchman/docs/cmd/find.html
-func (c *Client) Find(dir string, patterns ...string) ([]File, string, error) {
+func (c *Client) Find(dir string, patterns ...string) ([]File, Clock, error) {
 	var s struct {
-		Clock string
+		Clock Clock
 		Files []File
 	}
 
@@ -27,7 +32,7 @@ func (c *Client) Find(dir string, patterns ...string) ([]File, string, error) {
 	}
 
 	if err := c.send(&s, params...); err != nil {
-		return nil, "", err
+		return nil, Clock{}, err
 	}
 
 	return s.Files, s.Clock, nil
@@ -91,20 +96,101 @@ func (c *Client) LogLevel(level string) error {
 }
 
 // https://facebook.github.io/watchman/docs/cmd/query.html
-func (c *Client) Query(dir string, conf QueryOptions) ([]File, string, error) {
+func (c *Client) Query(dir string, conf QueryOptions) ([]File, Clock, error) {
 	var s struct {
-		Clock           string
+		Clock           Clock
 		Files           []File
 		IsFreshInstance bool `json:"is_fresh_instance"`
 	}
 
+	if err := c.requireCapabilities(conf.capabilities()...); err != nil {
+		return nil, Clock{}, err
+	}
+
 	if err := c.send(&s, "query", dir, conf); err != nil {
-		return nil, "", err
+		return nil, Clock{}, err
 	}
 
 	return s.Files, s.Clock, nil
 }
 
+// QueryFunc runs a query like Query, but rather than collecting the results
+// into a slice it decodes the files one at a time and passes each to fn.
+// fn is called from the client's connection goroutine, so it must not call
+// any other Client methods.  If fn returns an error, the remaining files are
+// discarded and that error is returned.
+//
+// Files are only decoded directly off the socket while the client has no
+// subscriptions.  Otherwise subscription events could be mistaken for the
+// query response, so the files array is read into memory in full before fn
+// is called for each file, and memory use is no longer bounded
+func (c *Client) QueryFunc(dir string, conf QueryOptions, fn func(File) error) (clock Clock, isFreshInstance bool, err error) {
+	var s struct {
+		Clock           Clock
+		IsFreshInstance bool `json:"is_fresh_instance"`
+	}
+
+	if err := c.requireCapabilities(conf.capabilities()...); err != nil {
+		return Clock{}, false, err
+	}
+
+	decode := func(raw json.RawMessage) error {
+		var f File
+
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return err
+		}
+
+		return fn(f)
+	}
+
+	if err := c.sendStream(&s, decode, "query", dir, conf); err != nil {
+		return Clock{}, false, err
+	}
+
+	return s.Clock, s.IsFreshInstance, nil
+}
+
+// QuerySCM runs a source control aware query.  conf.Since is ignored in favor
+// of since.  If a saved state was requested but watchman could not find one,
+// the result is returned along with a *SavedStateError
+// https://facebook.github.io/watchman/docs/scm-query.html
+func (c *Client) QuerySCM(dir string, since SCMSince, conf QueryOptions) (*SCMQueryResult, error) {
+	var s struct {
+		Clock           Clock
+		Files           []File
+		IsFreshInstance bool            `json:"is_fresh_instance"`
+		SavedStateInfo  *SavedStateInfo `json:"saved-state-info"`
+	}
+
+	if err := c.requireCapabilities(append(conf.capabilities(), "scm-since")...); err != nil {
+		return nil, err
+	}
+
+	// the outer Since field shadows the embedded string field
+	params := struct {
+		QueryOptions
+		Since SCMSince `json:"since"`
+	}{conf, since}
+
+	if err := c.send(&s, "query", dir, params); err != nil {
+		return nil, err
+	}
+
+	res := &SCMQueryResult{
+		Clock:           s.Clock,
+		Files:           s.Files,
+		IsFreshInstance: s.IsFreshInstance,
+		SavedStateInfo:  s.SavedStateInfo,
+	}
+
+	if s.SavedStateInfo != nil && s.SavedStateInfo.Error != "" {
+		return res, &SavedStateError{Msg: s.SavedStateInfo.Error}
+	}
+
+	return res, nil
+}
+
 // https://facebook.github.io/watchman/docs/cmd/shutdown-server.html
 func (c *Client) ShutdownServer() (bool, error) {
 	var v struct {
@@ -119,31 +205,69 @@ func (c *Client) ShutdownServer() (bool, error) {
 }
 
 // https://facebook.github.io/watchman/docs/cmd/since.html
-func (c *Client) Since(dir string, clock string, patterns ...string) ([]File, string, error) {
+func (c *Client) Since(dir string, clock Clock, patterns ...string) ([]File, Clock, error) {
+	if clock.IsZero() {
+		return nil, Clock{}, ErrZeroClock
+	}
+
 	var s struct {
-		Clock string
+		Clock Clock
 		Files []File
 	}
 
-	params := []interface{}{"since", clock, dir}
+	params := []interface{}{"since", dir, clock}
 	for _, p := range patterns {
 		params = append(params, p)
 	}
 
 	if err := c.send(&s, params...); err != nil {
-		return nil, "", err
+		return nil, Clock{}, err
 	}
 
 	return s.Files, s.Clock, nil
 }
 
+// StateEnter asserts the named state on root.  Subscribers may defer or drop
+// notifications while the state is asserted
+// https://facebook.github.io/watchman/docs/cmd/state-enter.html
+func (c *Client) StateEnter(root string, opts StateOptions) error {
+	if err := c.requireCapabilities("cmd-state-enter"); err != nil {
+		return err
+	}
+
+	return c.send(nil, "state-enter", root, opts)
+}
+
+// StateLeave vacates a state previously asserted with StateEnter
+// https://facebook.github.io/watchman/docs/cmd/state-leave.html
+func (c *Client) StateLeave(root string, opts StateOptions) error {
+	if err := c.requireCapabilities("cmd-state-leave"); err != nil {
+		return err
+	}
+
+	return c.send(nil, "state-leave", root, opts)
+}
+
 // https://facebook.github.io/watchman/docs/cmd/subscribe.html
 func (c *Client) Subscribe(root, name string, opts *SubscriptionOptions) error {
-	return c.send(nil, "subscribe", root, name, opts)
+	// registered before sending so that no query is streamed directly
+	// once events for the subscription can arrive
+	c.addSub(root, name)
+
+	if err := c.send(nil, "subscribe", root, name, opts); err != nil {
+		c.removeSub(root, name)
+		return err
+	}
+
+	return nil
 }
 
 // https://facebook.github.io/watchman/docs/cmd/trigger.html
 func (c *Client) Trigger(root string, opts *TriggerOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	return c.send(nil, "trigger", root, opts)
 }
 
@@ -153,9 +277,9 @@ func (c *Client) TriggerDel(root, name string) error {
 }
 
 // https://facebook.github.io/watchman/docs/cmd/trigger-list.html
-func (c *Client) TriggerList(root string) ([]string, error) {
+func (c *Client) TriggerList(root string) ([]TriggerOptions, error) {
 	var v struct {
-		Triggers []string
+		Triggers []TriggerOptions
 	}
 
 	if err := c.send(&v, "trigger-list", root); err != nil {
@@ -167,7 +291,13 @@ func (c *Client) TriggerList(root string) ([]string, error) {
 
 // https://facebook.github.io/watchman/docs/cmd/unsubscribe.html
 func (c *Client) Unsubscribe(root, name string) error {
-	return c.send(nil, "unsubscribe", root, name)
+	if err := c.send(nil, "unsubscribe", root, name); err != nil {
+		return err
+	}
+
+	c.removeSub(root, name)
+
+	return nil
 }
 
 // https://facebook.github.io/watchman/docs/cmd/version.html
@@ -183,17 +313,30 @@ func (c *Client) Version() (string, error) {
 	return v.Version, nil
 }
 
+// Watch watches dir and returns a Root for it.  Relative paths are resolved
+// against the current working directory
 // https://facebook.github.io/watchman/docs/cmd/watch.html
-func (c *Client) Watch(dir string) error {
+func (c *Client) Watch(dir string) (*Root, error) {
 	var v struct {
-		Watch string
+		Watch   string `json:"watch"`
+		Warning string `json:"warning"`
+	}
+
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, err
 	}
 
 	if err := c.send(&v, "watch", dir); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if v.Watch == "" {
+		v.Watch = dir
+	}
+
+	return &Root{c: c, Path: v.Watch, Warning: v.Warning}, nil
 }
 
 // https://facebook.github.io/watchman/docs/cmd/watch-del.html
@@ -223,12 +366,30 @@ func (c *Client) WatchList() ([]string, error) {
 	return v.Roots, nil
 }
 
+// WatchProject watches the project root containing dir and returns a Root
+// for it.  Commands run through the Root are scoped to dir
 // https://facebook.github.io/watchman/docs/cmd/watch-project.html
-func (c *Client) WatchProject(dir string) error {
+func (c *Client) WatchProject(dir string) (*Root, error) {
 	var v struct {
 		Watch        string `json:"watch"`
 		RelativePath string `json:"relative_path"`
+		Warning      string `json:"warning"`
+	}
+
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.send(&v, "watch-project", dir); err != nil {
+		return nil, err
 	}
 
-	return c.send(&v, "watch-project", dir)
+	return &Root{
+		c:            c,
+		Path:         v.Watch,
+		RelativePath: v.RelativePath,
+		Warning:      v.Warning,
+	}, nil
 }
@@ -3,18 +3,23 @@ package kovacs
 import (
 	"fmt"
 	"os"
+	"path"
+	"strings"
 	"testing"
 )
 
 func TestClock(t *testing.T) {
 	c := mustGetConnectedClient(t)
-	c.Clock(testDir)
+
+	clock, err := c.Clock(testDir)
+	assert(t, err == nil, "clock err: %s", err)
+	assert(t, clock.Pid != 0, "unexpected clock %s", clock)
 }
 
 func TestWatch(t *testing.T) {
 	c := mustGetConnectedClient(t)
 
-	err := c.Watch(testDir)
+	_, err := c.Watch(testDir)
 	assert(t, err == nil, "unexpected watch error: %s", err)
 }
 
@@ -90,6 +95,29 @@ func TestQuery(t *testing.T) {
 	}))
 }
 
+func TestQuery_Options(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+
+	// case_sensitive makes the plain name term case insensitive
+	cs := false
+	files, _, err := c.Query(wd, QueryOptions{
+		Expression:    Name(CaseSensitive, Basename, "CLIENT.GO"),
+		CaseSensitive: &cs,
+	})
+
+	version, _ := c.Version()
+
+	if compareVersions(version, optionVersions["case_sensitive"]) < 0 {
+		cerr, ok := err.(*CapabilityError)
+		assert(t, ok && cerr.Capability == "case_sensitive", "expected a capability error, found %v", err)
+		return
+	}
+
+	assert(t, err == nil, "query err: %s", err)
+	assert(t, len(files) == 1 && files[0].Name == "client.go", "unexpected files %v", files)
+}
+
 func TestListCapabilities(t *testing.T) {
 	c := mustGetConnectedClient(t)
 
@@ -98,3 +126,293 @@ func TestListCapabilities(t *testing.T) {
 	assert(t, err == nil, "list capablities err: %s", err)
 	fmt.Printf("caps = %+v\n", caps)
 }
+
+func TestQueryFunc(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+
+	var n int
+	_, _, err := c.QueryFunc(wd, QueryOptions{Suffix: []string{"go"}}, func(f File) error {
+		if !strings.Contains(f.Name, "/") {
+			n++
+		}
+
+		return nil
+	})
+
+	assert(t, err == nil, "query err: %s", err)
+	assert(t, n == numFiles, "expected %d files, found %d", numFiles, n)
+}
+
+func TestQueryFunc_Concurrent(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+
+	conf := QueryOptions{Suffix: []string{"go"}}
+	errs := make(chan error, 20)
+
+	count := func(files []File) int {
+		var n int
+		for _, f := range files {
+			if !strings.Contains(f.Name, "/") {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			files, _, err := c.Query(wd, conf)
+			if err == nil && count(files) != numFiles {
+				err = fmt.Errorf("query: expected %d files, found %d", numFiles, count(files))
+			}
+
+			errs <- err
+		}()
+
+		go func() {
+			var files []File
+			_, _, err := c.QueryFunc(wd, conf, func(f File) error {
+				files = append(files, f)
+				return nil
+			})
+
+			if err == nil && count(files) != numFiles {
+				err = fmt.Errorf("query func: expected %d files, found %d", numFiles, count(files))
+			}
+
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		err := <-errs
+		assert(t, err == nil, "concurrent query err: %s", err)
+	}
+}
+
+func TestQueryInto(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+
+	var files []struct {
+		Name string
+		Size int64
+		Hash string
+	}
+
+	_, err := c.QueryInto(wd, QueryOptions{Suffix: []string{"go"}}, &files)
+
+	if ok, _ := c.HasCapability("field-content.sha1hex"); !ok {
+		cerr, isCap := err.(*CapabilityError)
+		assert(t, isCap && cerr.Capability == "field-content.sha1hex", "expected a capability error, found %v", err)
+		return
+	}
+
+	assert(t, err == nil, "query err: %s", err)
+
+	var n int
+	for _, f := range files {
+		if !strings.Contains(f.Name, "/") {
+			assert(t, len(f.Hash) == 40, "%s: unexpected hash %q", f.Name, f.Hash)
+			n++
+		}
+	}
+
+	assert(t, n == numFiles, "expected %d files, found %d", numFiles, n)
+}
+
+func TestCursor(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+	cur := c.Cursor(wd, "test-cursor")
+
+	_, fresh, err := cur.Query(QueryOptions{Suffix: []string{"go"}})
+	assert(t, err == nil, "cursor query err: %s", err)
+	assert(t, fresh, "expected a fresh instance for a new cursor")
+
+	files, fresh, err := cur.Query(QueryOptions{Suffix: []string{"go"}})
+	assert(t, err == nil, "cursor query err: %s", err)
+	assert(t, !fresh && len(files) == 0, "expected no changes, found %d files", len(files))
+
+	_, ok, err := cur.Position()
+	assert(t, err == nil, "cursor position err: %s", err)
+	assert(t, ok, "cursor not found on server")
+}
+
+func TestEvaluateMatchesServer(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	wd, _ := os.Getwd()
+
+	all, _, err := c.Query(wd, QueryOptions{Expression: True(), Fields: []string{"name", "exists", "size", "mode", "type"}})
+	assert(t, err == nil, "query err: %s", err)
+
+	exprs := []Expression{
+		Suffix("go"),
+		Match(CaseSensitive, Basename, "*_test.go"),
+		AllOf(Type(TypeRegularFile), Size(Gt, 4096)),
+		Not(Dirname(CaseSensitive, "test")),
+	}
+
+	for _, expr := range exprs {
+		files, _, err := c.Query(wd, QueryOptions{Expression: expr, Fields: []string{"name", "exists", "size", "mode", "type"}})
+		assert(t, err == nil, "query err: %s", err)
+
+		var n int
+		for i := range all {
+			ok, err := Evaluate(expr, &all[i])
+			assert(t, err == nil, "evaluate err: %s", err)
+
+			if ok {
+				n++
+			}
+		}
+
+		assert(t, n == len(files), "%s: server matched %d files, evaluator matched %d", expr, len(files), n)
+	}
+}
+
+func TestTriggerList(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	err := c.Trigger(testDir, &TriggerOptions{
+		Name:       "test-trigger",
+		Command:    []string{"true"},
+		Expression: Suffix("go"),
+		Stdin:      StdinDevNull,
+	})
+	assert(t, err == nil, "trigger err: %s", err)
+
+	defer c.TriggerDel(testDir, "test-trigger")
+
+	triggers, err := c.TriggerList(testDir)
+	assert(t, err == nil, "trigger list err: %s", err)
+	assert(t, len(triggers) == 1 && triggers[0].Name == "test-trigger", "unexpected triggers %+v", triggers)
+	assert(t, triggers[0].Command[0] == "true", "unexpected command %v", triggers[0].Command)
+}
+
+func TestReconcileTriggers(t *testing.T) {
+	c := mustGetConnectedClient(t)
+	defer c.TriggerDel(testDir, "test-reconcile")
+
+	desired := []TriggerOptions{{Name: "test-reconcile", Command: []string{"true"}, Expression: Suffix("go")}}
+
+	plan, err := c.ReconcileTriggers(testDir, desired, true)
+	assert(t, err == nil, "reconcile err: %s", err)
+	assert(t, len(plan) == 1 && plan[0].Op == TriggerInstall, "unexpected plan %s", plan)
+
+	_, err = c.ReconcileTriggers(testDir, desired, false)
+	assert(t, err == nil, "reconcile err: %s", err)
+
+	plan, err = c.PlanTriggers(testDir, desired)
+	assert(t, err == nil, "plan err: %s", err)
+	assert(t, len(plan) == 0, "expected an empty plan, found %s", plan)
+}
+
+func TestWatchProject(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	root, err := c.WatchProject(testDir)
+	assert(t, err == nil, "watch-project err: %s", err)
+	assert(t, root.Path != "", "unexpected empty root")
+	assert(t, path.Join(root.Path, root.RelativePath) == testDir, "expected %s, found %s and %s", testDir, root.Path, root.RelativePath)
+}
+
+func TestRoot(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	root, err := c.Watch(testDir)
+	assert(t, err == nil, "watch err: %s", err)
+
+	clock, err := root.Clock()
+	assert(t, err == nil, "clock err: %s", err)
+
+	err = root.StateEnter(StateOptions{Name: "kovacs-test"})
+	assert(t, err == nil, "state-enter err: %s", err)
+
+	err = root.StateLeave(StateOptions{Name: "kovacs-test"})
+	assert(t, err == nil, "state-leave err: %s", err)
+
+	files, _, err := root.Since(clock)
+	assert(t, err == nil, "since err: %s", err)
+	assert(t, len(files) == 0, "expected no changes, found %d", len(files))
+}
+
+func TestAcquireWatch(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	a, err := c.AcquireWatch(testDir, "a")
+	assert(t, err == nil, "acquire err: %s", err)
+
+	_, err = c.AcquireWatch(testDir, "b")
+	assert(t, err == nil, "acquire err: %s", err)
+
+	holders := c.WatchHolders()[a.Path]
+	assert(t, len(holders) == 2 && holders[0] == "a" && holders[1] == "b", "unexpected holders %v", holders)
+
+	err = c.ReleaseWatch(a.Path, "a")
+	assert(t, err == nil, "release err: %s", err)
+
+	roots, err := c.WatchList()
+	assert(t, err == nil, "watch-list err: %s", err)
+
+	var found bool
+	for _, r := range roots {
+		found = found || r == a.Path
+	}
+
+	assert(t, found, "expected %s to still be watched", a.Path)
+
+	err = c.ReleaseWatch(a.Path, "a")
+	assert(t, err == ErrWatchNotHeld, "expected ErrWatchNotHeld, found %v", err)
+
+	err = c.ReleaseWatch(a.Path, "b")
+	assert(t, err == nil, "release err: %s", err)
+	assert(t, len(c.WatchHolders()) == 0, "unexpected holders %v", c.WatchHolders())
+}
+
+func TestConfigChanged(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	root, err := c.Watch(testDir)
+	assert(t, err == nil, "watch err: %s", err)
+
+	diff, err := c.ConfigChanged(root.Path)
+	assert(t, err == nil, "config changed err: %s", err)
+	assert(t, len(diff) == 0, "unexpected diff %v", diff)
+}
+
+func TestGetPID(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	pid, err := c.GetPID()
+	assert(t, err == nil, "get-pid err: %s", err)
+	assert(t, pid > 0, "unexpected pid %d", pid)
+}
+
+func TestDebugGetAssertedStates(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	root, err := c.Watch(testDir)
+	assert(t, err == nil, "watch err: %s", err)
+
+	err = root.StateEnter(StateOptions{Name: "kovacs-debug"})
+	assert(t, err == nil, "state-enter err: %s", err)
+
+	states, err := c.DebugGetAssertedStates(root.Path)
+	assert(t, err == nil, "debug-get-asserted-states err: %s", err)
+	assert(t, len(states) == 1 && states[0].Name == "kovacs-debug", "unexpected states %v", states)
+
+	err = root.StateLeave(StateOptions{Name: "kovacs-debug"})
+	assert(t, err == nil, "state-leave err: %s", err)
+}
+
+func TestDebugRecrawl(t *testing.T) {
+	c := mustGetConnectedClient(t)
+
+	res, err := c.DebugRecrawl(testDir)
+	assert(t, err == nil, "debug-recrawl err: %s", err)
+	assert(t, res.Recrawl, "expected a recrawl to be scheduled")
+}
@@ -0,0 +1,290 @@
+package kovacs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigFile is the name of the per-root config file
+const ConfigFile = ".watchmanconfig"
+
+// Bounds outside of which settle and fsevents_latency are almost certainly
+// a units mistake.  settle is in milliseconds, fsevents_latency in seconds
+const (
+	maxSettle          = 60000
+	maxFSEventsLatency = 60
+)
+
+// globalConfigKeys are only read from the global config, so they have no
+// effect in a .watchmanconfig
+var globalConfigKeys = map[string]bool{
+	"root_files":             true,
+	"root_restrict_files":    true,
+	"enforce_root_files":     true,
+	"illegal_fstypes":        true,
+	"illegal_fstypes_advice": true,
+}
+
+// ReadConfig decodes a config from r
+func ReadConfig(r io.Reader) (*Config, error) {
+	var conf Config
+
+	if err := json.NewDecoder(r).Decode(&conf); err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// LoadConfig reads the .watchmanconfig in dir
+func LoadConfig(dir string) (*Config, error) {
+	f, err := os.Open(filepath.Join(dir, ConfigFile))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadConfig(f)
+}
+
+// Write encodes the config to w.  Unset keys are omitted
+func (conf *Config) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(conf, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// SaveConfig writes conf to the .watchmanconfig in dir.  Watchman only reads
+// the file when the root is watched, so the root must be re-watched for the
+// changes to take effect
+func SaveConfig(dir string, conf *Config) error {
+	var buf bytes.Buffer
+
+	if err := conf.Write(&buf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, ConfigFile), buf.Bytes(), 0644)
+}
+
+// A ConfigIssue describes a problem with a config key
+type ConfigIssue struct {
+	Key    string
+	Reason string
+}
+
+func (i ConfigIssue) String() string {
+	return i.Key + ": " + i.Reason
+}
+
+// LintConfig checks the .watchmanconfig in r for unknown keys, keys that are
+// only honored in the global config and the problems reported by Lint
+func LintConfig(r io.Reader) ([]ConfigIssue, error) {
+	b, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		raw    map[string]json.RawMessage
+		conf   Config
+		issues []ConfigIssue
+	)
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, err
+	}
+
+	known := configKeys()
+	keys := make([]string, 0, len(raw))
+
+	for key := range raw {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch {
+		case !known[key]:
+			issues = append(issues, ConfigIssue{key, "unknown key"})
+		case globalConfigKeys[key]:
+			issues = append(issues, ConfigIssue{key, "only honored in the global config"})
+		}
+	}
+
+	return append(issues, conf.Lint()...), nil
+}
+
+// Lint checks the config for out of range values and conflicting
+// ignore_dirs entries
+func (conf *Config) Lint() []ConfigIssue {
+	var issues []ConfigIssue
+
+	add := func(key, format string, args ...interface{}) {
+		issues = append(issues, ConfigIssue{key, fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case conf.Settle < 0:
+		add("settle", "must not be negative")
+	case conf.Settle > maxSettle:
+		add("settle", "%d is more than %d; settle is in milliseconds", conf.Settle, maxSettle)
+	}
+
+	switch {
+	case conf.FSEventsLatency < 0:
+		add("fsevents_latency", "must not be negative")
+	case conf.FSEventsLatency > maxFSEventsLatency:
+		add("fsevents_latency", "%g is more than %d; fsevents_latency is in seconds", conf.FSEventsLatency, maxFSEventsLatency)
+	}
+
+	ints := []struct {
+		key string
+		val int
+	}{
+		{"gc_age_seconds", conf.GCAgeSeconds},
+		{"gc_interval_seconds", conf.GCIntervalSeconds},
+		{"idle_reap_age_seconds", conf.IdleReapAgeSeconds},
+		{"hint_num_files_per_dir", conf.HintNumFilesPerDir},
+		{"hint_num_dirs", conf.HintNumDirs},
+		{"content_hash_max_items", conf.ContentHashMaxItems},
+		{"content_hash_max_warm_per_settle", conf.ContentHashMaxWarmPerSettle},
+		{"win32_rdcw_buf_size", conf.Win32RDCWBufSize},
+		{"win32_batch_latency_ms", conf.Win32BatchLatencyMs},
+	}
+
+	for _, i := range ints {
+		if i.val < 0 {
+			add(i.key, "must not be negative")
+		}
+	}
+
+	vcs := map[string]bool{}
+	for _, dir := range conf.IgnoreVCS {
+		vcs[dir] = true
+	}
+
+	var (
+		seen = map[string]bool{}
+		dirs []string
+	)
+
+	for _, dir := range conf.IgnoreDirs {
+		clean := path.Clean(dir)
+
+		switch {
+		case dir == "":
+			add("ignore_dirs", "empty entry")
+			continue
+		case path.IsAbs(dir):
+			add("ignore_dirs", "%q must be relative to the root", dir)
+			continue
+		case clean == "." || clean == ".." || strings.HasPrefix(clean, "../"):
+			add("ignore_dirs", "%q is not inside the root", dir)
+			continue
+		case seen[clean]:
+			add("ignore_dirs", "%q is listed more than once", dir)
+			continue
+		case vcs[clean]:
+			add("ignore_dirs", "%q is also in ignore_vcs", dir)
+		}
+
+		seen[clean] = true
+		dirs = append(dirs, clean)
+	}
+
+	for _, dir := range dirs {
+		for parent := path.Dir(dir); parent != "."; parent = path.Dir(parent) {
+			if seen[parent] {
+				add("ignore_dirs", "%q is already ignored by %q", dir, parent)
+				break
+			}
+		}
+	}
+
+	return issues
+}
+
+// configKeys returns the keys of every documented config option
+func configKeys() map[string]bool {
+	typ := reflect.TypeOf(Config{})
+	keys := make(map[string]bool, typ.NumField())
+
+	for i := 0; i < typ.NumField(); i++ {
+		tag := typ.Field(i).Tag.Get("json")
+		keys[strings.Split(tag, ",")[0]] = true
+	}
+
+	return keys
+}
+
+// DiffConfig returns the keys whose values differ between a and b, sorted
+func DiffConfig(a, b *Config) []string {
+	am, bm := configMap(a), configMap(b)
+
+	var keys []string
+
+	for key := range configKeys() {
+		if !reflect.DeepEqual(am[key], bm[key]) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func configMap(conf *Config) map[string]interface{} {
+	var m map[string]interface{}
+
+	b, _ := json.Marshal(conf)
+	json.Unmarshal(b, &m)
+
+	return m
+}
+
+// ConfigChanged compares the .watchmanconfig on disk at root with the config
+// the server loaded when root was watched, and returns the keys that differ.
+// A non-empty result means root must be re-watched to pick up the changes
+func (c *Client) ConfigChanged(root string) ([]string, error) {
+	loaded, err := c.GetConfig(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := LoadConfig(root)
+
+	if os.IsNotExist(err) {
+		disk, err = &Config{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffConfig(disk, loaded), nil
+}
@@ -0,0 +1,72 @@
+package kovacs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	conf := &Config{
+		Settle:          200,
+		IgnoreDirs:      []string{"node_modules", "build"},
+		FSEventsLatency: 0.05,
+	}
+
+	var buf bytes.Buffer
+
+	err := conf.Write(&buf)
+	assert(t, err == nil, "unexpected write err: %s", err)
+	assert(t, !strings.Contains(buf.String(), "gc_age_seconds"), "unexpected unset key in %s", buf.String())
+
+	read, err := ReadConfig(&buf)
+	assert(t, err == nil, "unexpected read err: %s", err)
+
+	diff := DiffConfig(conf, read)
+	assert(t, len(diff) == 0, "unexpected diff %v", diff)
+}
+
+func TestLintConfig(t *testing.T) {
+	src := `{
+		"settle": 120000,
+		"fsevents_latency": -1,
+		"root_files": [".git"],
+		"ignore_dir": ["x"],
+		"content_hash_warm": true,
+		"content_hash_max_warm_per_settle": 100,
+		"win32_rdcw_buf_size": 16384,
+		"win32_batch_latency_ms": 30,
+		"enable_parallel_crawl": true,
+		"ignore_vcs": [".git"],
+		"ignore_dirs": ["a", "a/b", "/abs", "../up", "c", "c/", ".git"]
+	}`
+
+	issues, err := LintConfig(strings.NewReader(src))
+	assert(t, err == nil, "unexpected lint err: %s", err)
+
+	expected := []string{
+		`ignore_dir: unknown key`,
+		`root_files: only honored in the global config`,
+		`settle: 120000 is more than 60000; settle is in milliseconds`,
+		`fsevents_latency: must not be negative`,
+		`ignore_dirs: "/abs" must be relative to the root`,
+		`ignore_dirs: "../up" is not inside the root`,
+		`ignore_dirs: "c/" is listed more than once`,
+		`ignore_dirs: ".git" is also in ignore_vcs`,
+		`ignore_dirs: "a/b" is already ignored by "a"`,
+	}
+
+	assert(t, len(issues) == len(expected), "expected %d issues, found %d: %v", len(expected), len(issues), issues)
+
+	for i := range expected {
+		assert(t, issues[i].String() == expected[i], "issue %d: expected %s, found %s", i, expected[i], issues[i])
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	a := &Config{Settle: 20, IgnoreDirs: []string{"a"}}
+	b := &Config{Settle: 20, IgnoreDirs: []string{"b"}, SuppressRecrawlWarnings: true}
+
+	diff := DiffConfig(a, b)
+	assert(t, len(diff) == 2 && diff[0] == "ignore_dirs" && diff[1] == "suppress_recrawl_warnings", "unexpected diff %v", diff)
+}
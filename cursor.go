@@ -0,0 +1,65 @@
+package kovacs
+
+// A Cursor is a watchman named cursor on a root.  The server remembers the
+// clock at which the cursor was last used, so each query through a Cursor
+// returns the changes since the previous one and advances it.  The cursor is
+// created on the server by its first query, which reports a fresh instance
+// https://facebook.github.io/watchman/docs/clockspec.html#named-cursors
+type Cursor struct {
+	c    *Client
+	Root string
+	Name string
+}
+
+// Cursor returns the named cursor for root
+func (c *Client) Cursor(root, name string) *Cursor {
+	return &Cursor{c: c, Root: root, Name: name}
+}
+
+// Clock returns the clockspec that refers to the cursor
+func (cur *Cursor) Clock() Clock {
+	return Clock{Cursor: cur.Name}
+}
+
+// Query runs a query since the cursor's position and advances the cursor.
+// conf.Since is overwritten.  isFreshInstance is true when the cursor was
+// just created or the server restarted, in which case files holds every
+// matching file in the root
+func (cur *Cursor) Query(conf QueryOptions) (files []File, isFreshInstance bool, err error) {
+	var s struct {
+		Files           []File
+		IsFreshInstance bool `json:"is_fresh_instance"`
+	}
+
+	conf.Since = cur.Clock().String()
+
+	if err := cur.c.requireCapabilities(conf.capabilities()...); err != nil {
+		return nil, false, err
+	}
+
+	if err := cur.c.send(&s, "query", cur.Root, conf); err != nil {
+		return nil, false, err
+	}
+
+	return s.Files, s.IsFreshInstance, nil
+}
+
+// Since returns the files matching patterns that changed since the cursor's
+// position and advances the cursor
+func (cur *Cursor) Since(patterns ...string) ([]File, error) {
+	files, _, err := cur.c.Since(cur.Root, cur.Clock(), patterns...)
+	return files, err
+}
+
+// Position returns the tick the cursor currently points to.  ok is false if
+// the cursor has not been created on the server yet
+func (cur *Cursor) Position() (ticks uint32, ok bool, err error) {
+	cursors, err := cur.c.DebugShowCursors(cur.Root)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	ticks, ok = cursors[cur.Name]
+	return ticks, ok, nil
+}
@@ -0,0 +1,168 @@
+package kovacs
+
+import "strings"
+
+// GetPID returns the process id of the watchman server
+// https://facebook.github.io/watchman/docs/cmd/get-pid.html
+func (c *Client) GetPID() (int, error) {
+	var v struct {
+		Pid int `json:"pid"`
+	}
+
+	if err := c.send(&v, "get-pid"); err != nil {
+		return 0, err
+	}
+
+	return v.Pid, nil
+}
+
+// DebugRecrawlResult is the response to debug-recrawl
+type DebugRecrawlResult struct {
+	Recrawl bool `json:"recrawl"` // whether the recrawl was scheduled
+}
+
+// DebugRecrawl forces a full recrawl of root
+func (c *Client) DebugRecrawl(root string) (*DebugRecrawlResult, error) {
+	var v DebugRecrawlResult
+
+	if err := c.send(&v, "debug-recrawl", root); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// DebugAgeoutResult is the response to debug-ageout
+type DebugAgeoutResult struct {
+	Ageout bool `json:"ageout"` // whether the age out ran
+}
+
+// DebugAgeout ages out deleted files on root that have not been observed
+// for at least seconds
+func (c *Client) DebugAgeout(root string, seconds int) (*DebugAgeoutResult, error) {
+	var v DebugAgeoutResult
+
+	if err := c.send(&v, "debug-ageout", root, seconds); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// DebugShowCursors returns the tick position of each named cursor on root,
+// keyed by cursor name
+func (c *Client) DebugShowCursors(root string) (map[string]uint32, error) {
+	var v struct {
+		Cursors map[string]uint32 `json:"cursors"`
+	}
+
+	if err := c.send(&v, "debug-show-cursors", root); err != nil {
+		return nil, err
+	}
+
+	cursors := make(map[string]uint32, len(v.Cursors))
+	for name, ticks := range v.Cursors {
+		cursors[strings.TrimPrefix(name, "n:")] = ticks
+	}
+
+	return cursors, nil
+}
+
+// DebugSubscriptions is the state of the publisher that delivers
+// subscription events for a root
+type DebugSubscriptions struct {
+	NextSerial  uint64                  `json:"next_serial"`
+	Subscribers []DebugSubscriber       `json:"subscribers"`
+	Items       []DebugSubscriptionItem `json:"items"`
+}
+
+// A DebugSubscriber is a subscriber to a root's publisher.  Info is the
+// description the subscriber registered with, which varies by the kind of
+// subscriber
+type DebugSubscriber struct {
+	Serial uint64                 `json:"serial"`
+	Info   map[string]interface{} `json:"info"`
+}
+
+// A DebugSubscriptionItem is an event queued for delivery to subscribers
+type DebugSubscriptionItem struct {
+	Serial  uint64                 `json:"serial"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// DebugGetSubscriptions returns the subscriptions registered on root by all
+// clients
+func (c *Client) DebugGetSubscriptions(root string) (*DebugSubscriptions, error) {
+	var v DebugSubscriptions
+
+	if err := c.requireCapabilities("cmd-debug-get-subscriptions"); err != nil {
+		return nil, err
+	}
+
+	if err := c.send(&v, "debug-get-subscriptions", root); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// DebugPoison marks root as poisoned, as if the watcher had failed, and
+// returns the poison message.  A poisoned root rejects commands until the
+// server is restarted
+func (c *Client) DebugPoison(root string) (string, error) {
+	var v struct {
+		Poison string `json:"poison"`
+	}
+
+	if err := c.send(&v, "debug-poison", root); err != nil {
+		return "", err
+	}
+
+	return v.Poison, nil
+}
+
+// DebugWatcherInfo is the response to debug-watcher-info.  The keys of Info
+// depend on the watcher in use, such as inotify or fsevents
+type DebugWatcherInfo struct {
+	Info map[string]interface{} `json:"watcher-debug-info"`
+}
+
+// DebugWatcherInfo returns the watcher specific debug information for root
+func (c *Client) DebugWatcherInfo(root string) (*DebugWatcherInfo, error) {
+	var v DebugWatcherInfo
+
+	if err := c.requireCapabilities("cmd-debug-watcher-info"); err != nil {
+		return nil, err
+	}
+
+	if err := c.send(&v, "debug-watcher-info", root); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// An AssertedState is a state asserted on a root and its disposition, such
+// as PendingEnter, Asserted, PendingLeave or Done
+type AssertedState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// DebugGetAssertedStates returns the states currently asserted on root with
+// StateEnter
+func (c *Client) DebugGetAssertedStates(root string) ([]AssertedState, error) {
+	var v struct {
+		States []AssertedState `json:"states"`
+	}
+
+	if err := c.requireCapabilities("cmd-debug-get-asserted-states"); err != nil {
+		return nil, err
+	}
+
+	if err := c.send(&v, "debug-get-asserted-states", root); err != nil {
+		return nil, err
+	}
+
+	return v.States, nil
+}
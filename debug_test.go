@@ -0,0 +1,23 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDebugSubscriptionsUnmarshal(t *testing.T) {
+	b := []byte(`{
+		"version": "4.9.0",
+		"next_serial": 3,
+		"subscribers": [{"serial": 1, "info": {"name": "sub"}}],
+		"items": [{"serial": 2, "payload": {"subscription": "sub"}}]
+	}`)
+
+	var v DebugSubscriptions
+	err := json.Unmarshal(b, &v)
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, v.NextSerial == 3, "unexpected next serial %d", v.NextSerial)
+	assert(t, len(v.Subscribers) == 1 && v.Subscribers[0].Info["name"] == "sub", "unexpected subscribers %+v", v.Subscribers)
+	assert(t, len(v.Items) == 1 && v.Items[0].Payload["subscription"] == "sub", "unexpected items %+v", v.Items)
+}
@@ -0,0 +1,461 @@
+package kovacs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Evaluate reports whether f matches expr, following the semantics the
+// watchman server applies to each term.  f.Name is the path relative to the
+// root.  Since terms compare against f.Oclock, f.Cclock, f.Mtime and f.Ctime,
+// so the corresponding fields must have been requested for a meaningful result.
+// pcre patterns are evaluated with the regexp package, which supports most but
+// not all pcre syntax, and empty only matches regular files since the contents
+// of a directory are not known.  since terms with a named cursor are resolved
+// by the server and return an error
+func Evaluate(expr Expression, f *File) (bool, error) {
+	if f == nil {
+		return false, fmt.Errorf("cannot evaluate %s against a nil file", expr)
+	}
+
+	return (&evaluator{file: f, meta: true}).eval(expr)
+}
+
+// EvaluatePath reports whether the file at name, relative to the root,
+// matches expr.  Only terms that depend on the file name can be evaluated
+// this way; size, type, exists, empty and since terms return an error
+func EvaluatePath(expr Expression, name string) (bool, error) {
+	return (&evaluator{file: &File{Name: name, Exists: true}}).eval(expr)
+}
+
+type evaluator struct {
+	file *File
+	meta bool // whether file metadata is available
+}
+
+func (ev *evaluator) eval(expr Expression) (bool, error) {
+	sl, ok := expr.(exprSlice)
+
+	if !ok || len(sl) == 0 {
+		return false, fmt.Errorf("invalid term %s", formatArg(expr))
+	}
+
+	name, _ := sl[0].(exprString)
+	args := sl[1:]
+
+	switch name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "allof":
+		for _, e := range args {
+			if ok, err := ev.eval(e); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case "anyof":
+		for _, e := range args {
+			if ok, err := ev.eval(e); err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	case "not":
+		if len(args) != 1 {
+			return false, fmt.Errorf("not expects 1 operand, found %d", len(args))
+		}
+
+		ok, err := ev.eval(args[0])
+		return !ok, err
+	case "suffix":
+		return ev.suffix(args)
+	case "name", "iname":
+		return ev.name(args, name == "iname")
+	case "match", "imatch":
+		return ev.match(args, name == "imatch")
+	case "pcre", "ipcre":
+		return ev.pcre(args, name == "ipcre")
+	case "dirname", "idirname":
+		return ev.dirname(args, name == "idirname")
+	}
+
+	if !ev.meta {
+		return false, fmt.Errorf("term %s requires file metadata", name)
+	}
+
+	switch name {
+	case "exists":
+		return ev.file.Exists, nil
+	case "empty":
+		return ev.file.Exists && fileType(ev.file) == TypeRegularFile && ev.file.Size == 0, nil
+	case "size":
+		if !ev.file.Exists {
+			return false, nil
+		}
+
+		return relation(args, int64(ev.file.Size))
+	case "type":
+		if len(args) != 1 {
+			return false, fmt.Errorf("type expects 1 argument, found %d", len(args))
+		}
+
+		typ, ok := args[0].(exprString)
+
+		if !ok {
+			return false, fmt.Errorf("invalid type %s", formatArg(args[0]))
+		}
+
+		return fileType(ev.file) == string(typ), nil
+	case "since":
+		return ev.since(args)
+	}
+
+	return false, fmt.Errorf("unknown term %s", formatArg(sl[0]))
+}
+
+// scoped returns the portion of the file name that scope applies to
+func (ev *evaluator) scoped(args exprSlice, i int) (string, error) {
+	if len(args) <= i {
+		return path.Base(ev.file.Name), nil
+	}
+
+	switch args[i] {
+	case exprString(Basename.string):
+		return path.Base(ev.file.Name), nil
+	case exprString(Wholename.string):
+		return ev.file.Name, nil
+	}
+
+	return "", fmt.Errorf("invalid scope %s", formatArg(args[i]))
+}
+
+func stringArgs(e Expression) ([]string, error) {
+	switch e := e.(type) {
+	case exprString:
+		return []string{string(e)}, nil
+	case exprSlice:
+		strs := make([]string, len(e))
+
+		for i := range e {
+			s, ok := e[i].(exprString)
+
+			if !ok {
+				return nil, fmt.Errorf("expected a string, found %s", formatArg(e[i]))
+			}
+
+			strs[i] = string(s)
+		}
+
+		return strs, nil
+	}
+
+	return nil, fmt.Errorf("expected a string or list of strings, found %s", formatArg(e))
+}
+
+func (ev *evaluator) suffix(args exprSlice) (bool, error) {
+	if len(args) != 1 {
+		return false, fmt.Errorf("suffix expects 1 argument, found %d", len(args))
+	}
+
+	suffixes, err := stringArgs(args[0])
+
+	if err != nil {
+		return false, err
+	}
+
+	base := strings.ToLower(path.Base(ev.file.Name))
+
+	for _, s := range suffixes {
+		if strings.HasSuffix(base, "."+strings.ToLower(s)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (ev *evaluator) name(args exprSlice, fold bool) (bool, error) {
+	if len(args) < 1 {
+		return false, fmt.Errorf("name expects a name argument")
+	}
+
+	names, err := stringArgs(args[0])
+
+	if err != nil {
+		return false, err
+	}
+
+	s, err := ev.scoped(args, 1)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, n := range names {
+		if s == n || (fold && strings.EqualFold(s, n)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (ev *evaluator) match(args exprSlice, fold bool) (bool, error) {
+	if len(args) < 1 {
+		return false, fmt.Errorf("match expects a pattern argument")
+	}
+
+	pattern, ok := args[0].(exprString)
+
+	if !ok {
+		return false, fmt.Errorf("invalid pattern %s", formatArg(args[0]))
+	}
+
+	s, err := ev.scoped(args, 1)
+
+	if err != nil {
+		return false, err
+	}
+
+	var opts MatchOptions
+
+	if len(args) > 2 {
+		obj, ok := args[2].(exprObject)
+
+		if !ok {
+			return false, fmt.Errorf("invalid match options %s", formatArg(args[2]))
+		}
+
+		opts.IncludeDotFiles = obj["includedotfiles"] == exprBool(true)
+		opts.NoEscape = obj["noescape"] == exprBool(true)
+	}
+
+	var (
+		cs    = CaseSensitive
+		scope = Basename
+	)
+
+	if fold {
+		cs = CaseInsensitive
+	}
+
+	if len(args) > 1 && args[1] == exprString(Wholename.string) {
+		scope = Wholename
+	}
+
+	return WildMatch(string(pattern), s, cs, scope, opts), nil
+}
+
+func (ev *evaluator) pcre(args exprSlice, fold bool) (bool, error) {
+	if len(args) < 1 {
+		return false, fmt.Errorf("pcre expects a pattern argument")
+	}
+
+	pattern, ok := args[0].(exprString)
+
+	if !ok {
+		return false, fmt.Errorf("invalid pattern %s", formatArg(args[0]))
+	}
+
+	s, err := ev.scoped(args, 1)
+
+	if err != nil {
+		return false, err
+	}
+
+	p := string(pattern)
+
+	if fold {
+		p = "(?i)" + p
+	}
+
+	re, err := regexp.Compile(p)
+
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(s), nil
+}
+
+func (ev *evaluator) dirname(args exprSlice, fold bool) (bool, error) {
+	if len(args) < 1 {
+		return false, fmt.Errorf("dirname expects a directory argument")
+	}
+
+	dir, ok := args[0].(exprString)
+
+	if !ok {
+		return false, fmt.Errorf("invalid directory %s", formatArg(args[0]))
+	}
+
+	var (
+		name = ev.file.Name
+		d    = strings.TrimSuffix(string(dir), "/")
+		rest string
+	)
+
+	if fold {
+		name, d = strings.ToLower(name), strings.ToLower(d)
+	}
+
+	if d == "" {
+		rest = name
+	} else if strings.HasPrefix(name, d+"/") {
+		rest = name[len(d)+1:]
+	} else {
+		return false, nil
+	}
+
+	depth := int64(strings.Count(rest, "/"))
+
+	if len(args) < 2 {
+		return true, nil
+	}
+
+	rel, ok := args[1].(exprSlice)
+
+	if !ok || len(rel) == 0 || rel[0] != exprString("depth") {
+		return false, fmt.Errorf("invalid depth relation %s", formatArg(args[1]))
+	}
+
+	return relation(rel[1:], depth)
+}
+
+// relation evaluates an [op, operand] pair against val
+func relation(args exprSlice, val int64) (bool, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("expected an operator and operand, found %s", formatArg(args))
+	}
+
+	op, _ := args[0].(exprString)
+	n, ok := args[1].(exprInt)
+
+	if !ok {
+		return false, fmt.Errorf("invalid operand %s", formatArg(args[1]))
+	}
+
+	switch (Relation{string(op)}) {
+	case Eq:
+		return val == int64(n), nil
+	case Ne:
+		return val != int64(n), nil
+	case Gt:
+		return val > int64(n), nil
+	case Ge:
+		return val >= int64(n), nil
+	case Lt:
+		return val < int64(n), nil
+	case Le:
+		return val <= int64(n), nil
+	}
+
+	return false, fmt.Errorf("invalid operator %s", formatArg(args[0]))
+}
+
+func (ev *evaluator) since(args exprSlice) (bool, error) {
+	if len(args) < 1 {
+		return false, fmt.Errorf("since expects a clock or timestamp argument")
+	}
+
+	field := ClockFieldObserved
+
+	if len(args) > 1 {
+		f, ok := args[1].(exprString)
+
+		if !ok {
+			return false, fmt.Errorf("invalid since field %s", formatArg(args[1]))
+		}
+
+		field = string(f)
+	}
+
+	switch field {
+	case TimeFieldModified, TimeFieldCreated:
+		ts, ok := args[0].(exprInt)
+
+		if !ok {
+			return false, fmt.Errorf("since %s requires a timestamp, found %s", field, formatArg(args[0]))
+		}
+
+		if field == TimeFieldModified {
+			return ev.file.Mtime > int64(ts), nil
+		}
+
+		return ev.file.Ctime > int64(ts), nil
+	case ClockFieldObserved, ClockFieldCreated:
+		s, ok := args[0].(exprString)
+
+		if !ok {
+			return false, fmt.Errorf("since %s requires a clock, found %s", field, formatArg(args[0]))
+		}
+
+		since, err := ParseClock(string(s))
+
+		if err != nil {
+			return false, err
+		}
+
+		if since.IsCursor() {
+			return false, fmt.Errorf("since %s: named cursors can only be evaluated by the server", since)
+		}
+
+		fc := ev.file.Oclock
+		if field == ClockFieldCreated {
+			fc = ev.file.Cclock
+		}
+
+		clock, err := ParseClock(fc)
+
+		if err != nil {
+			return false, err
+		}
+
+		// a clock from another instance means every file is considered changed
+		if !clock.IsCursor() && !clock.SameInstance(since) {
+			return true, nil
+		}
+
+		cmp, err := clock.Compare(since)
+
+		if err != nil {
+			return false, err
+		}
+
+		return cmp > 0, nil
+	}
+
+	return false, fmt.Errorf("invalid since field %s", field)
+}
+
+// fileType returns the type character of f, as used by the type term
+func fileType(f *File) string {
+	if f.Type != "" {
+		return f.Type
+	}
+
+	switch f.Mode & 0170000 {
+	case 0010000:
+		return TypeNamedPipe
+	case 0020000:
+		return TypeCharacterSpecialFile
+	case 0040000:
+		return TypeDirectory
+	case 0060000:
+		return TypeBlockSpecialFile
+	case 0100000:
+		return TypeRegularFile
+	case 0120000:
+		return TypeSymbolicLink
+	case 0140000:
+		return TypeSocket
+	}
+
+	return "?"
+}
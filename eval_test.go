@@ -0,0 +1,92 @@
+package kovacs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	var (
+		goFile  = &File{Name: "src/pkg/a.go", Exists: true, Size: 100, Mode: 0100644, Oclock: "c:1:2:3:10", Mtime: 1000}
+		dotFile = &File{Name: ".hidden", Exists: true, Mode: 0100644}
+		dir     = &File{Name: "src/pkg", Exists: true, Size: 4096, Mode: 0040755}
+		deleted = &File{Name: "old.txt", Exists: false}
+	)
+
+	tests := []struct {
+		expr     Expression
+		file     *File
+		expected bool
+	}{
+		{True(), goFile, true},
+		{False(), goFile, false},
+		{Suffix("go"), goFile, true},
+		{Suffix("GO"), goFile, true},
+		{Suffix("txt", "go"), goFile, true},
+		{Suffix("txt"), goFile, false},
+		{Name(CaseSensitive, Basename, "a.go"), goFile, true},
+		{Name(CaseSensitive, Basename, "A.go"), goFile, false},
+		{Name(CaseInsensitive, Basename, "A.go"), goFile, true},
+		{Name(CaseSensitive, Wholename, "src/pkg/a.go"), goFile, true},
+		{Name(CaseSensitive, Wholename, "a.go"), goFile, false},
+		{Match(CaseSensitive, Basename, "*.go"), goFile, true},
+		{Match(CaseSensitive, Wholename, "src/*/*.go"), goFile, true},
+		{Match(CaseSensitive, Wholename, "src/*.go"), goFile, false},
+		{Match(CaseSensitive, Wholename, "src/**/*.go"), goFile, true},
+		{Match(CaseInsensitive, Basename, "A.GO"), goFile, true},
+		{Match(CaseSensitive, Basename, "*"), dotFile, false},
+		{MatchWithOptions(CaseSensitive, Basename, "*", MatchOptions{IncludeDotFiles: true}), dotFile, true},
+		{Pcre(CaseSensitive, Wholename, `^src/`), goFile, true},
+		{Pcre(CaseInsensitive, Basename, `^A\.`), goFile, true},
+		{Dirname(CaseSensitive, "src"), goFile, true},
+		{Dirname(CaseSensitive, "sr"), goFile, false},
+		{Dirname(CaseSensitive, ""), goFile, true},
+		{Dirname(CaseInsensitive, "SRC"), goFile, true},
+		{DirnameDepth(CaseSensitive, "src", Eq, 1), goFile, true},
+		{DirnameDepth(CaseSensitive, "src", Ge, 2), goFile, false},
+		{DirnameDepth(CaseSensitive, "src/pkg", Eq, 0), goFile, true},
+		{Size(Gt, 50), goFile, true},
+		{Size(Le, 50), goFile, false},
+		{Size(Ge, 0), deleted, false},
+		{Type(TypeRegularFile), goFile, true},
+		{Type(TypeDirectory), dir, true},
+		{Type(TypeRegularFile, TypeDirectory), dir, true},
+		{Exists(), deleted, false},
+		{Empty(), dotFile, true},
+		{Empty(), dir, false},
+		{mustExpr(SinceClock(mustParseClock("c:1:2:3:5"))), goFile, true},
+		{mustExpr(SinceClock(mustParseClock("c:1:2:3:10"))), goFile, false},
+		{mustExpr(SinceClock(mustParseClock("c:9:9:3:50"))), goFile, true},
+		{SinceTime(time.Unix(999, 0), TimeFieldModified), goFile, true},
+		{SinceTime(time.Unix(1000, 0), TimeFieldModified), goFile, false},
+		{AllOf(Suffix("go"), Not(Dirname(CaseSensitive, "vendor"))), goFile, true},
+		{AnyOf(Suffix("txt"), Exists()), deleted, true},
+		{AllOf(), goFile, true},
+		{AnyOf(), goFile, false},
+	}
+
+	for _, test := range tests {
+		ok, err := Evaluate(test.expr, test.file)
+
+		assert(t, err == nil, "%s: unexpected err: %s", test.expr, err)
+		assert(t, ok == test.expected, "%s on %s: expected %t, found %t", test.expr, test.file.Name, test.expected, ok)
+	}
+}
+
+func TestEvaluatePath(t *testing.T) {
+	ok, err := EvaluatePath(AllOf(Suffix("go"), Dirname(CaseSensitive, "src")), "src/a.go")
+	assert(t, err == nil && ok, "expected match, found %t (%v)", ok, err)
+
+	_, err = EvaluatePath(Size(Gt, 0), "src/a.go")
+	assert(t, err != nil, "expected an error for a metadata term")
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	f := &File{Name: "a.go", Exists: true, Oclock: "c:1:2:3:9"}
+
+	_, err := Evaluate(mustExpr(SinceClock(mustParseClock("n:foo"))), f)
+	assert(t, err != nil, "expected an error for a named cursor")
+
+	_, err = Evaluate(Suffix("go"), nil)
+	assert(t, err != nil, "expected an error for a nil file")
+}
@@ -1,12 +1,13 @@
 package kovacs
 
 import (
-	"fmt"
+	"strings"
 	"time"
 )
 
 type ExpressionScope struct{ string }
 type CaseSensitivity struct{ bool }
+type Relation struct{ string }
 
 var (
 	Wholename = ExpressionScope{"wholename"}
@@ -14,16 +15,64 @@ var (
 
 	CaseSensitive   = CaseSensitivity{true}
 	CaseInsensitive = CaseSensitivity{false}
+
+	Eq = Relation{"eq"}
+	Ne = Relation{"ne"}
+	Gt = Relation{"gt"}
+	Ge = Relation{"ge"}
+	Lt = Relation{"lt"}
+	Le = Relation{"le"}
 )
 
+// An Expression is a watchman query expression.  String returns the
+// expression in the syntax accepted by ParseExpressionText
 type Expression interface {
 	noopExpr()
+	String() string
 }
 
 type exprSlice []Expression
 
 func (sl exprSlice) noopExpr() {}
 
+func (sl exprSlice) String() string {
+	if len(sl) == 0 {
+		return formatArg(sl)
+	}
+
+	name, ok := sl[0].(exprString)
+
+	if _, known := termCapabilities[string(name)]; !ok || !known {
+		return formatArg(sl)
+	}
+
+	switch {
+	case (name == "allof" || name == "anyof") && len(sl) > 2:
+		op := " and "
+		if name == "anyof" {
+			op = " or "
+		}
+
+		parts := make([]string, len(sl)-1)
+		for i, e := range sl[1:] {
+			parts[i] = formatOperand(e, name == "anyof")
+		}
+
+		return strings.Join(parts, op)
+	case name == "not" && len(sl) == 2:
+		return "not " + formatOperand(sl[1], false)
+	case len(sl) == 1:
+		return string(name)
+	}
+
+	args := make([]string, len(sl)-1)
+	for i, e := range sl[1:] {
+		args[i] = formatArg(e)
+	}
+
+	return string(name) + "(" + strings.Join(args, ", ") + ")"
+}
+
 func fromStringSlice(strs []string) exprSlice {
 	sl := make(exprSlice, len(strs))
 	for i := range strs {
@@ -35,7 +84,23 @@ func fromStringSlice(strs []string) exprSlice {
 
 type exprString string
 
-func (s exprString) noopExpr() {}
+func (s exprString) noopExpr()      {}
+func (s exprString) String() string { return formatArg(s) }
+
+type exprInt int64
+
+func (i exprInt) noopExpr()      {}
+func (i exprInt) String() string { return formatArg(i) }
+
+type exprBool bool
+
+func (b exprBool) noopExpr()      {}
+func (b exprBool) String() string { return formatArg(b) }
+
+type exprObject map[string]Expression
+
+func (o exprObject) noopExpr()      {}
+func (o exprObject) String() string { return formatArg(o) }
 
 func caseName(name string, cs CaseSensitivity) exprString {
 	if cs == CaseSensitive {
@@ -57,7 +122,23 @@ func AnyOf(expr ...Expression) Expression {
 
 // https://facebook.github.io/watchman/docs/expr/dirname.html
 func Dirname(cs CaseSensitivity, dir string) Expression {
-	return nil
+	return exprSlice{caseName("dirname", cs), exprString(dir)}
+}
+
+// DirnameDepth matches files within dir whose depth below dir satisfies
+// the relation.  Files directly inside dir have a depth of 0
+// https://facebook.github.io/watchman/docs/expr/dirname.html
+func DirnameDepth(cs CaseSensitivity, dir string, rel Relation, depth int) Expression {
+	return exprSlice{
+		caseName("dirname", cs),
+		exprString(dir),
+		exprSlice{exprString("depth"), exprString(rel.string), exprInt(depth)},
+	}
+}
+
+// https://facebook.github.io/watchman/docs/expr/false.html
+func False() Expression {
+	return exprSlice{exprString("false")}
 }
 
 // https://facebook.github.io/watchman/docs/expr/empty.html
@@ -72,12 +153,46 @@ func Exists() Expression {
 
 // https://facebook.github.io/watchman/docs/expr/match.html
 func Match(cs CaseSensitivity, scope ExpressionScope, pattern string) Expression {
-	return exprSlice{caseName("match", cs), exprString(pattern), exprString(scope.string)}
+	return MatchWithOptions(cs, scope, pattern, MatchOptions{})
+}
+
+// MatchOptions are the optional flags of the match expression
+type MatchOptions struct {
+	IncludeDotFiles bool // allow wildcards to match a leading dot
+	NoEscape        bool // treat backslash as a literal character
+}
+
+// MatchWithOptions is Match with explicit match flags
+// https://facebook.github.io/watchman/docs/expr/match.html
+func MatchWithOptions(cs CaseSensitivity, scope ExpressionScope, pattern string, opts MatchOptions) Expression {
+	expr := exprSlice{caseName("match", cs), exprString(pattern), exprString(scope.string)}
+
+	flags := exprObject{}
+
+	if opts.IncludeDotFiles {
+		flags["includedotfiles"] = exprBool(true)
+	}
+
+	if opts.NoEscape {
+		flags["noescape"] = exprBool(true)
+	}
+
+	if len(flags) > 0 {
+		expr = append(expr, flags)
+	}
+
+	return expr
 }
 
 // https://facebook.github.io/watchman/docs/expr/name.html
 func Name(cs CaseSensitivity, scope ExpressionScope, names ...string) Expression {
-	return exprSlice{caseName("name", cs), fromStringSlice(names), exprString("basename")}
+	var arg Expression = fromStringSlice(names)
+
+	if len(names) == 1 {
+		arg = exprString(names[0])
+	}
+
+	return exprSlice{caseName("name", cs), arg, exprString(scope.string)}
 }
 
 // https://facebook.github.io/watchman/docs/expr/not.html
@@ -87,12 +202,35 @@ func Not(expr Expression) Expression {
 
 // https://facebook.github.io/watchman/docs/expr/pcre.html
 func Pcre(cs CaseSensitivity, scope ExpressionScope, pattern string) Expression {
-	return exprSlice{caseName("pcre", cs), exprString(pattern), exprString("basename")}
+	return exprSlice{caseName("pcre", cs), exprString(pattern), exprString(scope.string)}
 }
 
+// SinceClock matches files whose observed clock is after clock.  A zero
+// clock returns ErrZeroClock
 // https://facebook.github.io/watchman/docs/expr/since.html
-func SinceClock(clock string) Expression {
-	return exprSlice{exprString("since"), exprString(clock), exprString("oclock")}
+func SinceClock(clock Clock) (Expression, error) {
+	return SinceClockField(clock, ClockFieldObserved)
+}
+
+const (
+	ClockFieldObserved = "oclock"
+	ClockFieldCreated  = "cclock"
+)
+
+// SinceClockField matches files whose observed or created clock is after
+// clock.  An empty field uses the server default, oclock.  A zero clock
+// returns ErrZeroClock
+// https://facebook.github.io/watchman/docs/expr/since.html
+func SinceClockField(clock Clock, field string) (Expression, error) {
+	if clock.IsZero() {
+		return nil, ErrZeroClock
+	}
+
+	if field == "" {
+		return exprSlice{exprString("since"), exprString(clock.String())}, nil
+	}
+
+	return exprSlice{exprString("since"), exprString(clock.String()), exprString(field)}, nil
 }
 
 const (
@@ -100,19 +238,30 @@ const (
 	TimeFieldCreated  = "ctime"
 )
 
+// https://facebook.github.io/watchman/docs/expr/since.html
 func SinceTime(t time.Time, tf string) Expression {
-	val := fmt.Sprintf("%d", t.Unix())
-	return exprSlice{exprString("since"), exprString(val), exprString(tf)}
+	return exprSlice{exprString("since"), exprInt(t.Unix()), exprString(tf)}
 }
 
 // https://facebook.github.io/watchman/docs/expr/size.html
-func Size() Expression {
-	return nil
+func Size(rel Relation, size int64) Expression {
+	return exprSlice{exprString("size"), exprString(rel.string), exprInt(size)}
 }
 
+// Suffix matches files with any of the given suffixes.  Passing more than
+// one suffix uses the array form, which requires the suffix-set capability
 // https://facebook.github.io/watchman/docs/expr/suffix.html
-func Suffix(suffix string) Expression {
-	return exprSlice{exprString("suffix"), exprString(suffix)}
+func Suffix(suffix string, more ...string) Expression {
+	if len(more) == 0 {
+		return exprSlice{exprString("suffix"), exprString(suffix)}
+	}
+
+	return exprSlice{exprString("suffix"), fromStringSlice(append([]string{suffix}, more...))}
+}
+
+// https://facebook.github.io/watchman/docs/expr/true.html
+func True() Expression {
+	return exprSlice{exprString("true")}
 }
 
 const (
@@ -126,7 +275,89 @@ const (
 	TypeSolarisDoor          = "D"
 )
 
+// Type matches files of any of the given types
 // https://facebook.github.io/watchman/docs/expr/type.html
-func Type(typ string) Expression {
-	return exprSlice{exprString("type"), exprString(typ)}
+func Type(typ string, more ...string) Expression {
+	if len(more) == 0 {
+		return exprSlice{exprString("type"), exprString(typ)}
+	}
+
+	expr := exprSlice{exprString("anyof"), exprSlice{exprString("type"), exprString(typ)}}
+	for _, t := range more {
+		expr = append(expr, exprSlice{exprString("type"), exprString(t)})
+	}
+
+	return expr
+}
+
+// termCapabilities maps each expression term to the server capability
+// that advertises support for it
+var termCapabilities = map[string]string{
+	"allof":    "term-allof",
+	"anyof":    "term-anyof",
+	"dirname":  "term-dirname",
+	"idirname": "term-idirname",
+	"empty":    "term-empty",
+	"exists":   "term-exists",
+	"false":    "term-false",
+	"match":    "term-match",
+	"imatch":   "term-imatch",
+	"name":     "term-name",
+	"iname":    "term-iname",
+	"not":      "term-not",
+	"pcre":     "term-pcre",
+	"ipcre":    "term-ipcre",
+	"since":    "term-since",
+	"size":     "term-size",
+	"suffix":   "term-suffix",
+	"true":     "term-true",
+	"type":     "term-type",
+}
+
+// ExpressionCapabilities returns the server capabilities needed to
+// evaluate expr
+func ExpressionCapabilities(expr Expression) []string {
+	var (
+		caps []string
+		seen = map[string]bool{}
+	)
+
+	add := func(c string) {
+		if !seen[c] {
+			seen[c] = true
+			caps = append(caps, c)
+		}
+	}
+
+	var walk func(Expression)
+	walk = func(expr Expression) {
+		sl, ok := expr.(exprSlice)
+
+		if !ok || len(sl) == 0 {
+			return
+		}
+
+		name, _ := sl[0].(exprString)
+
+		if c, ok := termCapabilities[string(name)]; ok {
+			add(c)
+		}
+
+		switch name {
+		case "allof", "anyof", "not":
+			for _, e := range sl[1:] {
+				walk(e)
+			}
+		case "suffix":
+			if len(sl) > 1 {
+				if _, ok := sl[1].(exprSlice); ok {
+					add("suffix-set")
+				}
+			}
+		}
+	}
+
+	walk(expr)
+
+	return caps
 }
@@ -0,0 +1,130 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func assertExprJSON(t *testing.T, expr Expression, expected string) {
+	b, err := json.Marshal(expr)
+
+	assert(t, err == nil, "unexpected marshal err: %s", err)
+	assert(t, string(b) == expected, "expected %s, found %s", expected, b)
+}
+
+func TestDirname(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected string
+	}{
+		{Dirname(CaseSensitive, "foo"), `["dirname","foo"]`},
+		{Dirname(CaseInsensitive, "foo"), `["idirname","foo"]`},
+		{DirnameDepth(CaseSensitive, "foo", Ge, 2), `["dirname","foo",["depth","ge",2]]`},
+		{DirnameDepth(CaseSensitive, "foo", Eq, 0), `["dirname","foo",["depth","eq",0]]`},
+		{DirnameDepth(CaseSensitive, "foo", Ne, 1), `["dirname","foo",["depth","ne",1]]`},
+		{DirnameDepth(CaseSensitive, "foo", Gt, 1), `["dirname","foo",["depth","gt",1]]`},
+		{DirnameDepth(CaseInsensitive, "foo", Lt, 3), `["idirname","foo",["depth","lt",3]]`},
+		{DirnameDepth(CaseSensitive, "foo", Le, 3), `["dirname","foo",["depth","le",3]]`},
+	}
+
+	for _, test := range tests {
+		assertExprJSON(t, test.expr, test.expected)
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected string
+	}{
+		{Size(Eq, 0), `["size","eq",0]`},
+		{Size(Ne, 0), `["size","ne",0]`},
+		{Size(Gt, 50000000), `["size","gt",50000000]`},
+		{Size(Ge, 1), `["size","ge",1]`},
+		{Size(Lt, 1024), `["size","lt",1024]`},
+		{Size(Le, 1<<40), `["size","le",1099511627776]`},
+		{
+			AllOf(Type(TypeRegularFile), Not(Size(Lt, 1024))),
+			`["allof",["type","f"],["not",["size","lt",1024]]]`,
+		},
+		{
+			AnyOf(Size(Gt, 10), Empty()),
+			`["anyof",["size","gt",10],["empty"]]`,
+		},
+	}
+
+	for _, test := range tests {
+		assertExprJSON(t, test.expr, test.expected)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected string
+	}{
+		{Name(CaseSensitive, Basename, "Makefile"), `["name","Makefile","basename"]`},
+		{Name(CaseSensitive, Wholename, "src/Makefile"), `["name","src/Makefile","wholename"]`},
+		{Name(CaseInsensitive, Basename, "a", "b"), `["iname",["a","b"],"basename"]`},
+		{Name(CaseInsensitive, Wholename, "a", "b"), `["iname",["a","b"],"wholename"]`},
+
+		{Match(CaseSensitive, Basename, "*.go"), `["match","*.go","basename"]`},
+		{Match(CaseSensitive, Wholename, "src/**/*.go"), `["match","src/**/*.go","wholename"]`},
+		{Match(CaseInsensitive, Basename, "*.go"), `["imatch","*.go","basename"]`},
+		{Match(CaseInsensitive, Wholename, "*.go"), `["imatch","*.go","wholename"]`},
+		{
+			MatchWithOptions(CaseSensitive, Basename, "*", MatchOptions{IncludeDotFiles: true}),
+			`["match","*","basename",{"includedotfiles":true}]`,
+		},
+		{
+			MatchWithOptions(CaseInsensitive, Wholename, `\*`, MatchOptions{NoEscape: true}),
+			`["imatch","\\*","wholename",{"noescape":true}]`,
+		},
+		{
+			MatchWithOptions(CaseSensitive, Wholename, "*", MatchOptions{IncludeDotFiles: true, NoEscape: true}),
+			`["match","*","wholename",{"includedotfiles":true,"noescape":true}]`,
+		},
+
+		{Pcre(CaseSensitive, Basename, `^\w+$`), `["pcre","^\\w+$","basename"]`},
+		{Pcre(CaseSensitive, Wholename, `^src/`), `["pcre","^src/","wholename"]`},
+		{Pcre(CaseInsensitive, Basename, `^a`), `["ipcre","^a","basename"]`},
+		{Pcre(CaseInsensitive, Wholename, `^a`), `["ipcre","^a","wholename"]`},
+	}
+
+	for _, test := range tests {
+		assertExprJSON(t, test.expr, test.expected)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected string
+	}{
+		{True(), `["true"]`},
+		{False(), `["false"]`},
+		{Exists(), `["exists"]`},
+		{Empty(), `["empty"]`},
+		{mustExpr(SinceClock(mustParseClock("c:1:2:3:4"))), `["since","c:1:2:3:4","oclock"]`},
+		{mustExpr(SinceClockField(mustParseClock("c:1:2:3:4"), ClockFieldCreated)), `["since","c:1:2:3:4","cclock"]`},
+		{mustExpr(SinceClockField(mustParseClock("n:foo"), "")), `["since","n:foo"]`},
+		{SinceTime(time.Unix(1500000000, 0), TimeFieldModified), `["since",1500000000,"mtime"]`},
+		{Suffix("go"), `["suffix","go"]`},
+		{Suffix("go", "mod"), `["suffix",["go","mod"]]`},
+		{Type(TypeRegularFile), `["type","f"]`},
+		{Type(TypeRegularFile, TypeSymbolicLink), `["anyof",["type","f"],["type","l"]]`},
+	}
+
+	for _, test := range tests {
+		assertExprJSON(t, test.expr, test.expected)
+	}
+}
+
+func TestExpressionCapabilities(t *testing.T) {
+	caps := ExpressionCapabilities(AllOf(Suffix("go", "mod"), Not(DirnameDepth(CaseInsensitive, "vendor", Ge, 1)), True()))
+	expected := "term-allof,term-suffix,suffix-set,term-not,term-idirname,term-true"
+
+	assert(t, strings.Join(caps, ",") == expected, "expected %s, found %v", expected, caps)
+}
@@ -0,0 +1,188 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// QueryInto runs a query and decodes the files into dest, which must be a
+// pointer to a slice of structs.  The watchman fields to request are derived
+// from the struct: a `watchman:"name"` tag selects the field name, `watchman:"-"`
+// skips the struct field, and untagged fields are converted to snake case
+// (MtimeMs becomes mtime_ms).  Hash and ContentSHA1Hex are taken to mean
+// content.sha1hex.  Every field must name one of the fields watchman can
+// return, so other names need a watchman tag.  conf.Fields is ignored
+func (c *Client) QueryInto(dir string, conf QueryOptions, dest interface{}) (Clock, error) {
+	rv := reflect.ValueOf(dest)
+
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return Clock{}, errors.New("QueryInto dest must be a pointer to a slice")
+	}
+
+	sl := rv.Elem()
+	fields, err := structFields(sl.Type().Elem())
+
+	if err != nil {
+		return Clock{}, err
+	}
+
+	conf.Fields = make([]string, len(fields))
+	for i := range fields {
+		conf.Fields[i] = fields[i].name
+	}
+
+	var s struct {
+		Clock Clock
+	}
+
+	if err := c.requireCapabilities(conf.capabilities()...); err != nil {
+		return Clock{}, err
+	}
+
+	sl.SetLen(0)
+
+	decode := func(raw json.RawMessage) error {
+		v := reflect.New(sl.Type().Elem()).Elem()
+
+		if err := decodeFields(raw, fields, v); err != nil {
+			return err
+		}
+
+		sl.Set(reflect.Append(sl, v))
+		return nil
+	}
+
+	if err := c.sendStream(&s, decode, "query", dir, conf); err != nil {
+		return Clock{}, err
+	}
+
+	return s.Clock, nil
+}
+
+// fileFields are the field names that may be requested for each file
+// https://facebook.github.io/watchman/docs/cmd/query.html#available-fields
+var fileFields = map[string]bool{
+	"name": true, "exists": true, "cclock": true, "oclock": true,
+	"ctime": true, "ctime_ms": true, "ctime_us": true, "ctime_ns": true, "ctime_f": true,
+	"mtime": true, "mtime_ms": true, "mtime_us": true, "mtime_ns": true, "mtime_f": true,
+	"size": true, "mode": true, "uid": true, "gid": true, "ino": true, "dev": true,
+	"nlink": true, "new": true, "type": true, "symlink_target": true, "content.sha1hex": true,
+}
+
+// fieldAliases map the snake cased names of common untagged struct fields to
+// the watchman field they stand for
+var fieldAliases = map[string]string{
+	"hash":            "content.sha1hex",
+	"content_sha1hex": "content.sha1hex",
+}
+
+type structField struct {
+	name  string
+	index int
+}
+
+var fieldCache sync.Map // map[reflect.Type][]structField
+
+func structFields(t reflect.Type) ([]structField, error) {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]structField), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot query into %s, expected a struct", t)
+	}
+
+	var fields []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Tag.Get("watchman")
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(f.Name)
+
+			if alias, ok := fieldAliases[name]; ok {
+				name = alias
+			}
+		}
+
+		if !fileFields[name] {
+			return nil, fmt.Errorf("%s.%s: %q is not a watchman field, set one with a watchman tag", t, f.Name, name)
+		}
+
+		fields = append(fields, structField{name: name, index: i})
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s has no exported fields", t)
+	}
+
+	fieldCache.Store(t, fields)
+	return fields, nil
+}
+
+// decodeFields sets the fields of v from a single element of a files array.
+// When only one field is requested, watchman returns bare values
+// instead of objects
+func decodeFields(raw json.RawMessage, fields []structField, v reflect.Value) error {
+	if len(fields) == 1 {
+		return json.Unmarshal(raw, v.Field(fields[0].index).Addr().Interface())
+	}
+
+	var obj map[string]json.RawMessage
+
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		val, ok := obj[f.name]
+
+		if !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(val, v.Field(f.index).Addr().Interface()); err != nil {
+			return fmt.Errorf("field %s: %s", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+func snakeCase(s string) string {
+	var (
+		b    strings.Builder
+		prev rune
+	)
+
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('_')
+			}
+
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+
+		prev = r
+	}
+
+	return b.String()
+}
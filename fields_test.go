@@ -0,0 +1,60 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for in, expected := range map[string]string{
+		"Name":    "name",
+		"MtimeMs": "mtime_ms",
+		"UID":     "uid",
+		"Ctime_f": "ctime_f",
+	} {
+		out := snakeCase(in)
+		assert(t, out == expected, "snakeCase(%s): expected %s, found %s", in, expected, out)
+	}
+}
+
+func TestDecodeFields(t *testing.T) {
+	type file struct {
+		Name string
+		Size int64
+		Hash string
+		skip bool
+	}
+
+	fields, err := structFields(reflect.TypeOf(file{}))
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(fields) == 3 && fields[2].name == "content.sha1hex", "unexpected fields %v", fields)
+
+	var f file
+	raw := json.RawMessage(`{"name": "a.go", "size": 12, "content.sha1hex": "abc"}`)
+	err = decodeFields(raw, fields, reflect.ValueOf(&f).Elem())
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, f == file{Name: "a.go", Size: 12, Hash: "abc"}, "unexpected file %+v", f)
+}
+
+func TestDecodeFields_Single(t *testing.T) {
+	var f struct{ Name string }
+
+	fields, _ := structFields(reflect.TypeOf(f))
+	err := decodeFields(json.RawMessage(`"a.go"`), fields, reflect.ValueOf(&f).Elem())
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, f.Name == "a.go", "unexpected name %s", f.Name)
+}
+
+func TestStructFields_Unknown(t *testing.T) {
+	type file struct {
+		Name  string
+		Owner string
+	}
+
+	_, err := structFields(reflect.TypeOf(file{}))
+	assert(t, err != nil && strings.Contains(err.Error(), `"owner" is not a watchman field`), "unexpected err: %v", err)
+}
@@ -0,0 +1,248 @@
+package kovacs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// An IgnoreIssue describes an ignore pattern that could not be converted
+// exactly
+type IgnoreIssue struct {
+	Line    int
+	Pattern string
+	Reason  string
+}
+
+func (i IgnoreIssue) String() string {
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Pattern, i.Reason)
+}
+
+// IgnoreRules is an ignore file converted to an expression
+type IgnoreRules struct {
+	// Expression matches the ignored files and the contents of ignored
+	// directories.  Use Not(Expression) to exclude them from a query
+	Expression Expression
+
+	// Issues lists the patterns that were skipped or whose conversion
+	// is an approximation
+	Issues []IgnoreIssue
+}
+
+// gitignore and hgignore wildcards match leading dots
+var ignoreMatchOpts = MatchOptions{IncludeDotFiles: true}
+
+// ParseGitignore converts a .gitignore file into an expression.  dir is the
+// directory containing the file, relative to the watched root, and is empty
+// for a .gitignore at the root
+// https://git-scm.com/docs/gitignore
+func ParseGitignore(r io.Reader, dir string) (*IgnoreRules, error) {
+	var (
+		rules    IgnoreRules
+		ignored  []Expression
+		positive bool
+	)
+
+	dir = strings.Trim(dir, "/")
+	sc := bufio.NewScanner(r)
+
+	for line := 1; sc.Scan(); line++ {
+		pattern := trimTrailingSpace(sc.Text())
+
+		if pattern == "" || pattern[0] == '#' {
+			continue
+		}
+
+		negated := pattern[0] == '!'
+
+		if negated {
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+			pattern = pattern[1:]
+		}
+
+		if pattern == "" || pattern == "/" {
+			continue
+		}
+
+		if err := ValidateGlob(pattern); err != nil {
+			rules.Issues = append(rules.Issues, IgnoreIssue{line, sc.Text(), err.Error()})
+			continue
+		}
+
+		expr := gitignorePattern(pattern, dir)
+
+		// the last matching pattern wins, so a negation removes its matches
+		// from everything before it
+		if negated {
+			if positive {
+				rules.Issues = append(rules.Issues, IgnoreIssue{line, sc.Text(),
+					"files inside a directory excluded by an earlier pattern are re-included, git does not re-include them"})
+			}
+
+			ignored = []Expression{AllOf(AnyOf(ignored...), Not(expr))}
+		} else {
+			ignored = append(ignored, expr)
+			positive = true
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules.finish(ignored)
+}
+
+func (rules *IgnoreRules) finish(ignored []Expression) (*IgnoreRules, error) {
+	expr, err := Simplify(AnyOf(ignored...))
+
+	if err != nil {
+		return nil, err
+	}
+
+	rules.Expression = expr
+	return rules, nil
+}
+
+// trimTrailingSpace removes trailing spaces that are not escaped
+func trimTrailingSpace(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+
+	return s
+}
+
+// gitignorePattern returns an expression that matches the files matched by
+// pattern, along with everything inside the directories it matches
+func gitignorePattern(pattern, dir string) Expression {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var self, within Expression
+
+	if strings.Contains(pattern, "/") {
+		// anchored to the directory of the ignore file
+		whole := path.Join(dir, strings.TrimPrefix(pattern, "/"))
+		self = MatchWithOptions(CaseSensitive, Wholename, whole, ignoreMatchOpts)
+		within = MatchWithOptions(CaseSensitive, Wholename, whole+"/**", ignoreMatchOpts)
+	} else {
+		self = MatchWithOptions(CaseSensitive, Basename, pattern, ignoreMatchOpts)
+		within = MatchWithOptions(CaseSensitive, Wholename, "**/"+pattern+"/**", ignoreMatchOpts)
+
+		if dir != "" {
+			self = AllOf(Dirname(CaseSensitive, dir), self)
+			within = MatchWithOptions(CaseSensitive, Wholename, dir+"/**/"+pattern+"/**", ignoreMatchOpts)
+		}
+	}
+
+	if dirOnly {
+		self = AllOf(Type(TypeDirectory), self)
+	}
+
+	return AnyOf(self, within)
+}
+
+// ParseHgignore converts a .hgignore file into an expression.  The regexp,
+// glob, path and rootfilesin syntaxes are supported, switched with "syntax:"
+// lines or per pattern prefixes; other pattern kinds are reported as issues.
+// Regular expressions are matched against the path
+// of each file with the pcre term, so a pattern anchored with $ does not
+// match the contents of a directory it matches
+// https://www.mercurial-scm.org/doc/hgignore.5.html
+func ParseHgignore(r io.Reader) (*IgnoreRules, error) {
+	var (
+		rules   IgnoreRules
+		ignored []Expression
+		syntax  = "regexp"
+	)
+
+	sc := bufio.NewScanner(r)
+
+	for line := 1; sc.Scan(); line++ {
+		pattern := strings.TrimSpace(stripHgComment(sc.Text()))
+
+		if pattern == "" {
+			continue
+		}
+
+		if strings.HasPrefix(pattern, "syntax:") {
+			syntax = strings.TrimSpace(strings.TrimPrefix(pattern, "syntax:"))
+			continue
+		}
+
+		patSyntax := syntax
+
+		for _, prefix := range hgPrefixes {
+			if strings.HasPrefix(pattern, prefix+":") {
+				patSyntax, pattern = prefix, pattern[len(prefix)+1:]
+				break
+			}
+		}
+
+		switch patSyntax {
+		case "re", "regexp", "relre":
+			if strings.HasSuffix(pattern, "$") {
+				rules.Issues = append(rules.Issues, IgnoreIssue{line, sc.Text(),
+					"anchored at the end, so the contents of matching directories are not matched"})
+			}
+
+			ignored = append(ignored, Pcre(CaseSensitive, Wholename, pattern))
+		case "glob", "relglob", "rootglob":
+			if err := ValidateGlob(pattern); err != nil {
+				rules.Issues = append(rules.Issues, IgnoreIssue{line, sc.Text(), err.Error()})
+				continue
+			}
+
+			// glob patterns are unrooted, they match at any directory
+			if patSyntax != "rootglob" {
+				pattern = "**/" + pattern
+			}
+
+			ignored = append(ignored, AnyOf(
+				MatchWithOptions(CaseSensitive, Wholename, pattern, ignoreMatchOpts),
+				MatchWithOptions(CaseSensitive, Wholename, pattern+"/**", ignoreMatchOpts),
+			))
+		case "path":
+			pattern = strings.Trim(pattern, "/")
+			ignored = append(ignored, AnyOf(Name(CaseSensitive, Wholename, pattern), Dirname(CaseSensitive, pattern)))
+		case "rootfilesin":
+			pattern = strings.Trim(pattern, "/")
+			ignored = append(ignored, DirnameDepth(CaseSensitive, pattern, Eq, 0))
+		default:
+			rules.Issues = append(rules.Issues, IgnoreIssue{line, sc.Text(), "unsupported syntax " + patSyntax})
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules.finish(ignored)
+}
+
+// hgPrefixes are the pattern kinds that may prefix an hgignore pattern.
+// relpath depends on the working directory, and include, subinclude,
+// listfile and set read other files or revisions, so they are reported as
+// unsupported
+var hgPrefixes = []string{
+	"re", "regexp", "relre", "glob", "relglob", "rootglob", "path", "rootfilesin",
+	"relpath", "include", "subinclude", "listfile", "listfile0", "set",
+}
+
+// stripHgComment removes a # comment, honoring \# escapes
+func stripHgComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '#':
+			return strings.Replace(s[:i], `\#`, "#", -1)
+		}
+	}
+
+	return strings.Replace(s, `\#`, "#", -1)
+}
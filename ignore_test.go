@@ -0,0 +1,138 @@
+package kovacs
+
+import (
+	"strings"
+	"testing"
+)
+
+func ignoreFile(name string) *File {
+	f := &File{Name: name, Exists: true, Mode: 0100644}
+
+	if strings.HasSuffix(name, "/") {
+		f.Name, f.Mode = strings.TrimSuffix(name, "/"), 0040755
+	}
+
+	return f
+}
+
+func TestParseGitignore(t *testing.T) {
+	gitignore := `
+# build output
+*.log
+/bin
+build/
+docs/*.html
+\#notes
+trailing.txt   
+!keep.log
+`
+
+	rules, err := ParseGitignore(strings.NewReader(gitignore), "")
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(rules.Issues) == 1 && rules.Issues[0].Line == 9, "unexpected issues %v", rules.Issues)
+
+	tests := []struct {
+		name    string
+		ignored bool
+	}{
+		{"a.log", true},
+		{"src/.hidden.log", true},
+		{"src/keep.log", false},
+		{"bin", true},
+		{"bin/tool", true},
+		{"src/bin", false},
+		{"build/", true},
+		{"src/build/", true},
+		{"src/build/out.o", true},
+		{"build", false},
+		{"docs/index.html", true},
+		{"docs/api/index.html", false},
+		{"#notes", true},
+		{"trailing.txt", true},
+		{"main.go", false},
+	}
+
+	for _, test := range tests {
+		ok, err := Evaluate(rules.Expression, ignoreFile(test.name))
+
+		assert(t, err == nil, "unexpected err: %s", err)
+		assert(t, ok == test.ignored, "%s: expected ignored %t, found %t", test.name, test.ignored, ok)
+	}
+}
+
+func TestParseGitignore_Subdir(t *testing.T) {
+	rules, err := ParseGitignore(strings.NewReader("*.tmp\n/out\n"), "web")
+	assert(t, err == nil, "unexpected err: %s", err)
+
+	for name, ignored := range map[string]bool{
+		"a.tmp":           false,
+		"web/a.tmp":       true,
+		"web/deep/a.tmp":  true,
+		"web/out/app.js":  true,
+		"out/app.js":      false,
+		"web/deep/out/x":  false,
+		"other/web/a.tmp": false,
+	} {
+		ok, err := Evaluate(rules.Expression, ignoreFile(name))
+
+		assert(t, err == nil, "unexpected err: %s", err)
+		assert(t, ok == ignored, "%s: expected ignored %t, found %t", name, ignored, ok)
+	}
+}
+
+func TestParseGitignore_Invalid(t *testing.T) {
+	rules, err := ParseGitignore(strings.NewReader("[abc\n"), "")
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(rules.Issues) == 1, "expected an issue, found %v", rules.Issues)
+	assert(t, rules.Expression.String() == "false", "expected false, found %s", rules.Expression)
+}
+
+func TestParseHgignore(t *testing.T) {
+	hgignore := `
+syntax: glob
+*.pyc
+build
+
+syntax: regexp
+^generated/
+\.orig$ # merge leftovers
+foo:bar
+relglob:*.tmp
+path:docs/api
+rootfilesin:bin
+include:other/.hgignore
+relpath:local
+`
+
+	rules, err := ParseHgignore(strings.NewReader(hgignore))
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(rules.Issues) == 3, "unexpected issues %v", rules.Issues)
+	assert(t, rules.Issues[0].Line == 8, "unexpected issue %s", rules.Issues[0])
+	assert(t, rules.Issues[1].Pattern == "include:other/.hgignore", "unexpected issue %s", rules.Issues[1])
+	assert(t, rules.Issues[2].Pattern == "relpath:local", "unexpected issue %s", rules.Issues[2])
+
+	for name, ignored := range map[string]bool{
+		"a.pyc":           true,
+		"src/a.pyc":       true,
+		"build/x":         true,
+		"src/build/x":     true,
+		"generated/a.go":  true,
+		"src/generated/a": false,
+		"a.go.orig":       true,
+		"main.go":         false,
+		"foo:bar":         true,
+		"src/a.tmp":       true,
+		"docs/api":        true,
+		"docs/api/x.html": true,
+		"docs/apis":       false,
+		"src/docs/api":    false,
+		"bin/tool":        true,
+		"bin/sub/tool":    false,
+	} {
+		ok, err := Evaluate(rules.Expression, ignoreFile(name))
+
+		assert(t, err == nil, "unexpected err: %s", err)
+		assert(t, ok == ignored, "%s: expected ignored %t, found %t", name, ignored, ok)
+	}
+}
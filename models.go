@@ -1,5 +1,7 @@
 package kovacs
 
+import "encoding/json"
+
 var (
 	StdinDevNull     StdinType = stdinString("/dev/null")
 	StdinNamePerLine StdinType = stdinString("NAME_PER_LINE")
@@ -17,44 +19,105 @@ type StdinArray []string
 
 func (s StdinArray) stdinNoop() {}
 
+// Config is the contents of a .watchmanconfig file or the global
+// watchman.json.  root_files, root_restrict_files, enforce_root_files and
+// the illegal_fstypes keys are only read from the global config
+// https://facebook.github.io/watchman/docs/config.html
 type Config struct {
-	Settle               int        `json:"settle"`
-	RootRestrictFiles    []string   `json:"root_restrict_files"`
-	RootFiles            [][]string `json:"root_files"`
-	EnforceRootFiles     bool       `json:"enforce_root_files"`
-	IllegalFSTypes       []string   `json:"illegal_fstypes"`
-	IllegalFSTypesAdvice string     `json:"illegal_fstypes_advice"`
-	IgnoreVCS            []string   `json:"ignore_vcs"`
-	IgnoreDirs           []string   `json:"ignore_dirs"`
-	GCAgeSeconds         int        `json:"gc_age_seconds"`
-	GCIntervalSeconds    int        `json:"gc_interval_seconds"`
-	FSEventsLatency      float64    `json:"fsevents_latency"`
-	IdleReapAgeSeconds   int        `json:"idle_reap_age_seconds"`
+	Settle                      int      `json:"settle,omitempty"`
+	RootRestrictFiles           []string `json:"root_restrict_files,omitempty"`
+	RootFiles                   []string `json:"root_files,omitempty"`
+	EnforceRootFiles            bool     `json:"enforce_root_files,omitempty"`
+	IllegalFSTypes              []string `json:"illegal_fstypes,omitempty"`
+	IllegalFSTypesAdvice        string   `json:"illegal_fstypes_advice,omitempty"`
+	IgnoreVCS                   []string `json:"ignore_vcs,omitempty"`
+	IgnoreDirs                  []string `json:"ignore_dirs,omitempty"`
+	GCAgeSeconds                int      `json:"gc_age_seconds,omitempty"`
+	GCIntervalSeconds           int      `json:"gc_interval_seconds,omitempty"`
+	FSEventsLatency             float64  `json:"fsevents_latency,omitempty"`
+	FSEventsTryResync           bool     `json:"fsevents_try_resync,omitempty"`
+	PreferSplitFSEventsWatcher  bool     `json:"prefer_split_fsevents_watcher,omitempty"`
+	IdleReapAgeSeconds          int      `json:"idle_reap_age_seconds,omitempty"`
+	HintNumFilesPerDir          int      `json:"hint_num_files_per_dir,omitempty"`
+	HintNumDirs                 int      `json:"hint_num_dirs,omitempty"`
+	SuppressRecrawlWarnings     bool     `json:"suppress_recrawl_warnings,omitempty"`
+	ContentHashMaxItems         int      `json:"content_hash_max_items,omitempty"`
+	ContentHashWarm             bool     `json:"content_hash_warm,omitempty"`
+	ContentHashMaxWarmPerSettle int      `json:"content_hash_max_warm_per_settle,omitempty"`
+	EnableParallelCrawl         bool     `json:"enable_parallel_crawl,omitempty"`
+	Win32RDCWBufSize            int      `json:"win32_rdcw_buf_size,omitempty"`
+	Win32BatchLatencyMs         int      `json:"win32_batch_latency_ms,omitempty"`
 }
 
+// File is a single file in a query result.  Only the requested fields are set
+// https://facebook.github.io/watchman/docs/cmd/query.html#available-fields
 type File struct {
-	Name    string
-	Exists  bool
-	Cclock  string
-	Oclock  string
-	Mtime   int64
-	MtimeMs int64
-	MtimeUs int64
-	MtimeNs int64
-	MtimeF  float64
-	Ctime   int64
-	CtimeMs int64
-	CtimeUs int64
-	CtimeNs int64
-	CtimeF  float64
-	Size    int
-	Mode    int
-	Uid     int
-	Gid     int
-	Ino     int
-	Dev     int
-	Nlink   int
-	New     bool
+	Name           string      `json:"name"`
+	Exists         bool        `json:"exists"`
+	Cclock         string      `json:"cclock"`
+	Oclock         string      `json:"oclock"`
+	Mtime          int64       `json:"mtime"`
+	MtimeMs        int64       `json:"mtime_ms"`
+	MtimeUs        int64       `json:"mtime_us"`
+	MtimeNs        int64       `json:"mtime_ns"`
+	MtimeF         float64     `json:"mtime_f"`
+	Ctime          int64       `json:"ctime"`
+	CtimeMs        int64       `json:"ctime_ms"`
+	CtimeUs        int64       `json:"ctime_us"`
+	CtimeNs        int64       `json:"ctime_ns"`
+	CtimeF         float64     `json:"ctime_f"`
+	Size           int         `json:"size"`
+	Mode           int         `json:"mode"`
+	Type           string      `json:"type"`
+	Uid            int         `json:"uid"`
+	Gid            int         `json:"gid"`
+	Ino            int         `json:"ino"`
+	Dev            int         `json:"dev"`
+	Nlink          int         `json:"nlink"`
+	New            bool        `json:"new"`
+	SymlinkTarget  string      `json:"symlink_target"`
+	ContentSHA1Hex ContentHash `json:"content.sha1hex"`
+}
+
+// A ContentHash is the content.sha1hex field of a file.  When watchman could
+// not hash the file, Err holds the reason and Hex is empty
+type ContentHash struct {
+	Hex string
+	Err string
+}
+
+func (h ContentHash) MarshalJSON() ([]byte, error) {
+	if h.Err != "" {
+		return json.Marshal(map[string]string{"error": h.Err})
+	}
+
+	return json.Marshal(h.Hex)
+}
+
+func (h *ContentHash) UnmarshalJSON(b []byte) error {
+	var hex *string
+
+	if err := json.Unmarshal(b, &hex); err == nil {
+		*h = ContentHash{}
+
+		if hex != nil {
+			h.Hex = *hex
+		}
+
+		return nil
+	}
+
+	var v struct {
+		Error string `json:"error"`
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*h = ContentHash{Err: v.Error}
+
+	return nil
 }
 
 type Path struct {
@@ -63,14 +126,58 @@ type Path struct {
 }
 
 type QueryOptions struct {
-	Suffix               []string   `json:"suffix,omitempty"`
-	Since                string     `json:"since,omitempty"`
-	Expression           Expression `json:"expression,omitempty"`
-	Fields               []string   `json:"fields,omitempty"`
-	Path                 []Path     `json:"path,omitempty"`
-	SyncTimeout          int        `json:"sync_timeout,omitempty"`
-	EmptyOnFreshInstance bool       `json:"empty_on_fresh_instance,omitempty"`
-	RelativeRoot         string     `json:"relative_root,omitempty"`
+	Suffix                   []string   `json:"suffix,omitempty"`
+	Since                    string     `json:"since,omitempty"`
+	Expression               Expression `json:"expression,omitempty"`
+	Fields                   []string   `json:"fields,omitempty"`
+	Path                     []Path     `json:"path,omitempty"`
+	SyncTimeout              int        `json:"sync_timeout,omitempty"`
+	LockTimeout              int        `json:"lock_timeout,omitempty"`
+	EmptyOnFreshInstance     bool       `json:"empty_on_fresh_instance,omitempty"`
+	RelativeRoot             string     `json:"relative_root,omitempty"`
+	CaseSensitive            *bool      `json:"case_sensitive,omitempty"`
+	DedupResults             bool       `json:"dedup_results,omitempty"`
+	AlwaysIncludeDirectories bool       `json:"always_include_directories,omitempty"`
+	FailIfNoSavedState       bool       `json:"fail_if_no_saved_state,omitempty"`
+}
+
+// capabilities returns the server capabilities needed by the options that are set.
+// Options the server does not advertise are checked against the version
+// that introduced them, see optionVersions
+func (o *QueryOptions) capabilities() []string {
+	var caps []string
+
+	if o.Expression != nil {
+		caps = append(caps, ExpressionCapabilities(o.Expression)...)
+	}
+
+	if o.LockTimeout != 0 {
+		caps = append(caps, "lock_timeout")
+	}
+
+	if o.CaseSensitive != nil {
+		caps = append(caps, "case_sensitive")
+	}
+
+	if o.DedupResults {
+		caps = append(caps, "dedup_results")
+	}
+
+	for _, f := range o.Fields {
+		if f == "content.sha1hex" {
+			caps = append(caps, "field-content.sha1hex")
+		}
+	}
+
+	if o.AlwaysIncludeDirectories {
+		caps = append(caps, "always_include_directories")
+	}
+
+	if o.FailIfNoSavedState {
+		caps = append(caps, "fail_if_no_saved_state")
+	}
+
+	return caps
 }
 
 type SubscriptionEvent struct {
@@ -81,22 +188,182 @@ type SubscriptionEvent struct {
 	Subscription string   `json:"subscription"`
 }
 
+// StateOptions names a state for StateEnter and StateLeave.  Metadata is
+// passed through to subscribers
+type StateOptions struct {
+	Name        string      `json:"name"`
+	Metadata    interface{} `json:"metadata,omitempty"`
+	SyncTimeout int         `json:"sync_timeout,omitempty"`
+}
+
 type SubscriptionOptions struct {
-	Since    string
-	Expr     Expression
-	Fields   []string
-	DeferVCS bool
+	Since        string     `json:"since,omitempty"`
+	Expr         Expression `json:"expression,omitempty"`
+	Fields       []string   `json:"fields,omitempty"`
+	DeferVCS     bool       `json:"defer_vcs,omitempty"`
+	RelativeRoot string     `json:"relative_root,omitempty"`
 }
 
 type TriggerOptions struct {
-	Name          string      `json:"name"`
-	Command       []string    `json:"command"`
-	AppendFiles   bool        `json:"append_files,omitempty"`
-	Expression    interface{} `json:"expression"`
-	Stdin         StdinType   `json:"stdin"`
-	Stdout        string      `json:"stdout"`
-	Stderr        string      `json:"stderr"`
-	MaxFilesStdin int         `json:"max_files_stdin"`
-	Chdir         string      `json:"chdir"`
-	RelativeRoot  string      `json:"relative_root"`
+	Name          string         `json:"name"`
+	Command       []string       `json:"command"`
+	AppendFiles   bool           `json:"append_files,omitempty"`
+	Expression    Expression     `json:"expression,omitempty"`
+	Stdin         StdinType      `json:"stdin,omitempty"`
+	Stdout        OutputRedirect `json:"stdout,omitempty"`
+	Stderr        OutputRedirect `json:"stderr,omitempty"`
+	MaxFilesStdin int            `json:"max_files_stdin,omitempty"`
+	Chdir         string         `json:"chdir,omitempty"`
+	RelativeRoot  string         `json:"relative_root,omitempty"`
+}
+
+// An OutputRedirect is a trigger stdout or stderr destination.  >file
+// truncates the file when the trigger runs and >>file appends to it
+type OutputRedirect string
+
+// RedirectTruncate returns a redirect that truncates file
+func RedirectTruncate(file string) OutputRedirect {
+	return OutputRedirect(">" + file)
+}
+
+// RedirectAppend returns a redirect that appends to file
+func RedirectAppend(file string) OutputRedirect {
+	return OutputRedirect(">>" + file)
+}
+
+// UnmarshalJSON decodes a trigger definition as returned by trigger-list
+func (t *TriggerOptions) UnmarshalJSON(b []byte) error {
+	type options TriggerOptions
+
+	var v struct {
+		options
+		Expression json.RawMessage `json:"expression"`
+		Stdin      json.RawMessage `json:"stdin"`
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*t = TriggerOptions(v.options)
+
+	if len(v.Expression) > 0 && string(v.Expression) != "null" {
+		expr, err := ParseExpression(v.Expression)
+
+		if err != nil {
+			return err
+		}
+
+		t.Expression = expr
+	}
+
+	if len(v.Stdin) > 0 && string(v.Stdin) != "null" {
+		stdin, err := parseStdin(v.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		t.Stdin = stdin
+	}
+
+	return nil
+}
+
+func parseStdin(b []byte) (StdinType, error) {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err == nil {
+		return stdinString(s), nil
+	}
+
+	var arr StdinArray
+
+	if err := json.Unmarshal(b, &arr); err != nil {
+		return nil, err
+	}
+
+	return arr, nil
+}
+
+// SCMSince is a source control aware since value for queries.  When only
+// MergebaseWith is set, watchman returns the files changed since the merge
+// base of the working copy and the named revision.  Subsequent queries should
+// pass the SCMSince of the Clock from the prior response
+// https://facebook.github.io/watchman/docs/scm-query.html
+type SCMSince struct {
+	Clock         string
+	Mergebase     string
+	MergebaseWith string
+	SavedState    *SavedState
+}
+
+func (s SCMSince) MarshalJSON() ([]byte, error) {
+	type scm struct {
+		Mergebase     string      `json:"mergebase,omitempty"`
+		MergebaseWith string      `json:"mergebase-with"`
+		SavedState    *SavedState `json:"saved-state,omitempty"`
+	}
+
+	return json.Marshal(struct {
+		Clock string `json:"clock,omitempty"`
+		SCM   scm    `json:"scm"`
+	}{
+		Clock: s.Clock,
+		SCM: scm{
+			Mergebase:     s.Mergebase,
+			MergebaseWith: s.MergebaseWith,
+			SavedState:    s.SavedState,
+		},
+	})
+}
+
+// SavedState asks watchman to look up the saved state nearest to the merge
+// base as part of an scm aware query
+type SavedState struct {
+	Storage  string      `json:"storage"`
+	Config   interface{} `json:"config,omitempty"`
+	CommitID string      `json:"commit-id,omitempty"`
+}
+
+// SavedStateInfo is the saved-state-info block of an scm aware query response.
+// The available keys depend on the storage type, so the full block is kept in Raw
+type SavedStateInfo struct {
+	CommitID  string          `json:"commit-id"`
+	LocalPath string          `json:"local-path"`
+	Error     string          `json:"error"`
+	Raw       json.RawMessage `json:"-"`
+}
+
+func (s *SavedStateInfo) UnmarshalJSON(b []byte) error {
+	type info SavedStateInfo
+
+	if err := json.Unmarshal(b, (*info)(s)); err != nil {
+		return err
+	}
+
+	s.Raw = make(json.RawMessage, len(b))
+	copy(s.Raw, b)
+
+	return nil
+}
+
+// SCMQueryResult is the result of an scm aware query.  Clock carries the
+// merge base, and Clock.SCMSince continues from it
+type SCMQueryResult struct {
+	Clock           Clock
+	Files           []File
+	IsFreshInstance bool
+	SavedStateInfo  *SavedStateInfo
+}
+
+// SavedStateError is returned when watchman was asked for a saved state but
+// could not provide one.  The query itself succeeded, so it is returned
+// alongside a usable SCMQueryResult
+type SavedStateError struct {
+	Msg string
+}
+
+func (e *SavedStateError) Error() string {
+	return "watchman saved state: " + e.Msg
 }
@@ -1,6 +1,9 @@
 package kovacs
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestStdinTypes(t *testing.T) {
 	// just make sure these compile
@@ -8,3 +11,96 @@ func TestStdinTypes(t *testing.T) {
 	var _ StdinType = StdinNamePerLine
 	var _ StdinType = StdinArray{"sdfkj", "sdflkjsdf"}
 }
+
+func TestSCMSinceMarshal(t *testing.T) {
+	b, err := json.Marshal(SCMSince{
+		MergebaseWith: "master",
+		SavedState:    &SavedState{Storage: "local", Config: map[string]string{"project": "foo"}},
+	})
+
+	assert(t, err == nil, "unexpected marshal err: %s", err)
+
+	expected := `{"scm":{"mergebase-with":"master","saved-state":{"storage":"local","config":{"project":"foo"}}}}`
+	assert(t, string(b) == expected, "expected %s, found %s", expected, b)
+}
+
+func TestQueryOptionsCapabilities(t *testing.T) {
+	var opts QueryOptions
+	assert(t, len(opts.capabilities()) == 0, "unexpected capabilities %v", opts.capabilities())
+
+	cs := false
+	opts = QueryOptions{CaseSensitive: &cs, LockTimeout: 100, DedupResults: true}
+	caps := opts.capabilities()
+
+	assert(t, len(caps) == 3, "expected 3 capabilities, found %v", caps)
+	assert(t, caps[0] == "lock_timeout" && caps[1] == "case_sensitive" && caps[2] == "dedup_results", "unexpected capabilities %v", caps)
+
+	opts = QueryOptions{Fields: []string{"name", "content.sha1hex"}}
+	caps = opts.capabilities()
+	assert(t, len(caps) == 1 && caps[0] == "field-content.sha1hex", "unexpected capabilities %v", caps)
+}
+
+func TestTriggerOptionsUnmarshal(t *testing.T) {
+	b := []byte(`{
+		"name": "assets",
+		"command": ["make", "assets"],
+		"append_files": true,
+		"expression": ["allof", ["suffix", "css"], ["not", ["dirname", "vendor"]]],
+		"stdin": ["name", "size"],
+		"stdout": ">>/tmp/assets.log",
+		"max_files_stdin": 100
+	}`)
+
+	var opts TriggerOptions
+	err := json.Unmarshal(b, &opts)
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, opts.Name == "assets" && len(opts.Command) == 2 && opts.AppendFiles, "unexpected options %+v", opts)
+	assert(t, opts.Stdout == ">>/tmp/assets.log" && opts.MaxFilesStdin == 100, "unexpected options %+v", opts)
+
+	expr := opts.Expression.String()
+	assert(t, expr == "suffix(css) and not dirname(vendor)", "unexpected expression %s", expr)
+
+	stdin, ok := opts.Stdin.(StdinArray)
+	assert(t, ok && len(stdin) == 2 && stdin[1] == "size", "unexpected stdin %#v", opts.Stdin)
+
+	err = json.Unmarshal([]byte(`{"name": "x", "stdin": "NAME_PER_LINE"}`), &opts)
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, opts.Stdin == StdinNamePerLine, "unexpected stdin %#v", opts.Stdin)
+	assert(t, opts.Expression == nil, "unexpected expression %v", opts.Expression)
+}
+
+func TestFileUnmarshal(t *testing.T) {
+	b := []byte(`[
+		{"name": "a", "mtime_ms": 1500, "ctime_f": 1.5, "content.sha1hex": "da39a3ee"},
+		{"name": "b", "content.sha1hex": {"error": "is a directory"}},
+		{"name": "c", "content.sha1hex": null}
+	]`)
+
+	var files []File
+	err := json.Unmarshal(b, &files)
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, files[0].MtimeMs == 1500 && files[0].CtimeF == 1.5, "unexpected file %+v", files[0])
+	assert(t, files[0].ContentSHA1Hex.Hex == "da39a3ee", "unexpected hash %+v", files[0].ContentSHA1Hex)
+	assert(t, files[1].ContentSHA1Hex.Err == "is a directory", "unexpected hash %+v", files[1].ContentSHA1Hex)
+	assert(t, files[2].ContentSHA1Hex == ContentHash{}, "unexpected hash %+v", files[2].ContentSHA1Hex)
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		cmp  int
+	}{
+		{"4.9.0", "4.9.0", 0},
+		{"3.8.0", "4.1.0", -1},
+		{"4.10.0", "4.9.0", 1},
+		{"2021.05.10.00", "4.9.0", 1},
+		{"4.9", "4.9.0", 0},
+	}
+
+	for _, test := range tests {
+		cmp := compareVersions(test.a, test.b)
+		assert(t, cmp == test.cmp, "compareVersions(%s, %s): expected %d, found %d", test.a, test.b, test.cmp, cmp)
+	}
+}
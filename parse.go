@@ -0,0 +1,637 @@
+package kovacs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A SyntaxError describes an expression that could not be parsed.  Offset
+// is the byte offset in the input where the error was detected.  Errors in
+// the terms of an expression are found after it has been built, so they are
+// located by Path instead, the array indices leading to the bad value in the
+// JSON form such as [2][0]
+type SyntaxError struct {
+	Offset int
+	Path   string
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("expression syntax error at %s: %s", e.Path, e.Msg)
+	}
+
+	return fmt.Sprintf("expression syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+// a termError is an invalid value found at path within an expression
+type termError struct {
+	path string
+	msg  string
+}
+
+func (e *termError) Error() string {
+	if e.path == "" {
+		return e.msg
+	}
+
+	return e.path + ": " + e.msg
+}
+
+func termErrorf(path, format string, args ...interface{}) error {
+	return &termError{path: path, msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseExpression parses an expression in the watchman JSON form, such as
+// ["allof", ["suffix", "go"], ["not", ["dirname", "vendor"]]]
+func ParseExpression(b []byte) (Expression, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+
+	if err := dec.Decode(&v); err != nil {
+		if serr, ok := err.(*json.SyntaxError); ok {
+			return nil, &SyntaxError{Offset: int(serr.Offset), Msg: serr.Error()}
+		}
+
+		return nil, &SyntaxError{Offset: int(dec.InputOffset()), Msg: err.Error()}
+	}
+
+	if dec.More() {
+		return nil, &SyntaxError{Offset: int(dec.InputOffset()), Msg: "unexpected data after expression"}
+	}
+
+	expr, err := fromJSONValue(v, "")
+
+	if err == nil {
+		err = checkTermPath(expr, "")
+	}
+
+	if terr, ok := err.(*termError); ok {
+		return nil, &SyntaxError{Path: terr.path, Msg: terr.msg}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+func fromJSONValue(v interface{}, path string) (Expression, error) {
+	switch v := v.(type) {
+	case string:
+		return exprString(v), nil
+	case bool:
+		return exprBool(v), nil
+	case json.Number:
+		i, err := v.Int64()
+
+		if err != nil {
+			return nil, termErrorf(path, "expected an integer, found %s", v)
+		}
+
+		return exprInt(i), nil
+	case []interface{}:
+		sl := make(exprSlice, len(v))
+
+		for i := range v {
+			e, err := fromJSONValue(v[i], fmt.Sprintf("%s[%d]", path, i))
+
+			if err != nil {
+				return nil, err
+			}
+
+			sl[i] = e
+		}
+
+		return sl, nil
+	case map[string]interface{}:
+		obj := make(exprObject, len(v))
+
+		for k := range v {
+			e, err := fromJSONValue(v[k], fmt.Sprintf("%s[%q]", path, k))
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj[k] = e
+		}
+
+		return obj, nil
+	}
+
+	return nil, termErrorf(path, "unexpected value %v", v)
+}
+
+// checkTerm verifies that expr is a known term and that the operands of
+// allof, anyof and not are terms as well.  Errors name the path to the
+// offending value
+func checkTerm(expr Expression) error {
+	return checkTermPath(expr, "")
+}
+
+func checkTermPath(expr Expression, path string) error {
+	sl, ok := expr.(exprSlice)
+
+	if !ok || len(sl) == 0 {
+		return termErrorf(path, "expected a term, found %s", formatArg(expr))
+	}
+
+	name, ok := sl[0].(exprString)
+
+	if !ok {
+		return termErrorf(path+"[0]", "expected a term name, found %s", formatArg(sl[0]))
+	}
+
+	if _, ok := termCapabilities[string(name)]; !ok {
+		return termErrorf(path+"[0]", "unknown term %q", string(name))
+	}
+
+	switch name {
+	case "allof", "anyof", "not":
+		for i, e := range sl[1:] {
+			if err := checkTermPath(e, fmt.Sprintf("%s[%d]", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseExpressionText parses the compact textual expression syntax, for
+// example
+//
+//	suffix(go) and not (dirname(vendor) or name("BUILD", wholename))
+//
+// Terms are written name(arg, ...), or just name when they take no arguments.
+// and, or and not map to allof, anyof and not, with not binding tightest and
+// or loosest.  Arguments are words, "quoted strings", integers, true, false,
+// [lists] and {key: value} objects, matching the JSON form of each term.  A
+// word is a run of letters, digits and any of _ . / * ? - + ~ @ % ^ $
+func ParseExpressionText(s string) (Expression, error) {
+	p := &parser{lex: lexer{src: s}}
+	p.next()
+
+	expr, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	// terms passed as arguments, such as allof([...]), are only known to be
+	// terms once the whole expression is built
+	if err := checkTerm(expr); err != nil {
+		if terr, ok := err.(*termError); ok {
+			return nil, &SyntaxError{Path: terr.path, Msg: terr.msg}
+		}
+
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokInt
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	val  string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return "string " + strconv.Quote(t.val)
+	}
+
+	return strconv.Quote(t.val)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isWordByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("_./*?-+~@%^$", c) >= 0
+}
+
+var intRe = regexp.MustCompile(`^-?[0-9]+$`)
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+
+	start := l.pos
+
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+
+	switch {
+	case strings.IndexByte("()[]{},:", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, val: string(c), pos: start}, nil
+	case c == '"':
+		l.pos++
+
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+
+			l.pos++
+		}
+
+		if l.pos >= len(l.src) {
+			return token{}, &SyntaxError{Offset: start, Msg: "unterminated string"}
+		}
+
+		l.pos++
+
+		var val string
+
+		if err := json.Unmarshal([]byte(l.src[start:l.pos]), &val); err != nil {
+			return token{}, &SyntaxError{Offset: start, Msg: "invalid string: " + err.Error()}
+		}
+
+		return token{kind: tokString, val: val, pos: start}, nil
+	case isWordByte(c):
+		for l.pos < len(l.src) && isWordByte(l.src[l.pos]) {
+			l.pos++
+		}
+
+		val := l.src[start:l.pos]
+
+		if intRe.MatchString(val) {
+			return token{kind: tokInt, val: val, pos: start}, nil
+		}
+
+		return token{kind: tokWord, val: val, pos: start}, nil
+	}
+
+	return token{}, &SyntaxError{Offset: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+
+	p.tok, p.err = p.lex.next()
+
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.lex.pos}
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	return &SyntaxError{Offset: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isPunct(val string) bool {
+	return p.tok.kind == tokPunct && p.tok.val == val
+}
+
+func (p *parser) isKeyword(val string) bool {
+	return p.tok.kind == tokWord && p.tok.val == val
+}
+
+func (p *parser) expect(val string) error {
+	if !p.isPunct(val) {
+		return p.errorf("expected %q, found %s", val, p.tok)
+	}
+
+	p.next()
+	return nil
+}
+
+func (p *parser) parseOr() (Expression, error) {
+	return p.parseInfix("or", "anyof", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	return p.parseInfix("and", "allof", p.parseUnary)
+}
+
+func (p *parser) parseInfix(op, term string, operand func() (Expression, error)) (Expression, error) {
+	expr, err := operand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isKeyword(op) {
+		return expr, nil
+	}
+
+	sl := exprSlice{exprString(term), expr}
+
+	for p.isKeyword(op) {
+		p.next()
+		expr, err := operand()
+
+		if err != nil {
+			return nil, err
+		}
+
+		sl = append(sl, expr)
+	}
+
+	return sl, nil
+}
+
+func (p *parser) parseUnary() (Expression, error) {
+	if p.isKeyword("not") {
+		p.next()
+		expr, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return exprSlice{exprString("not"), expr}, nil
+	}
+
+	if p.isPunct("(") {
+		p.next()
+		expr, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return expr, nil
+	}
+
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (Expression, error) {
+	if p.tok.kind != tokWord {
+		return nil, p.errorf("expected a term, found %s", p.tok)
+	}
+
+	name := p.tok.val
+
+	if _, ok := termCapabilities[name]; !ok {
+		return nil, p.errorf("unknown term %q", name)
+	}
+
+	p.next()
+
+	sl := exprSlice{exprString(name)}
+
+	if !p.isPunct("(") {
+		return sl, nil
+	}
+
+	p.next()
+
+	args, err := p.parseArgs(")")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sl, args...), nil
+}
+
+// parseArgs parses a comma separated list of arguments up to and including
+// the closing delimiter
+func (p *parser) parseArgs(closing string) (exprSlice, error) {
+	var args exprSlice
+
+	for !p.isPunct(closing) {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.parseArg()
+
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	p.next()
+
+	return args, nil
+}
+
+func (p *parser) parseArg() (Expression, error) {
+	tok := p.tok
+
+	switch {
+	case tok.kind == tokString:
+		p.next()
+		return exprString(tok.val), nil
+	case tok.kind == tokInt:
+		i, err := strconv.ParseInt(tok.val, 10, 64)
+
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.val)
+		}
+
+		p.next()
+		return exprInt(i), nil
+	case tok.kind == tokWord:
+		p.next()
+
+		switch tok.val {
+		case "true":
+			return exprBool(true), nil
+		case "false":
+			return exprBool(false), nil
+		}
+
+		if p.isPunct("(") {
+			p.next()
+			args, err := p.parseArgs(")")
+
+			if err != nil {
+				return nil, err
+			}
+
+			return append(exprSlice{exprString(tok.val)}, args...), nil
+		}
+
+		return exprString(tok.val), nil
+	case p.isPunct("["):
+		p.next()
+		return p.parseArgs("]")
+	case p.isPunct("{"):
+		p.next()
+		return p.parseObject()
+	}
+
+	return nil, p.errorf("expected an argument, found %s", tok)
+}
+
+func (p *parser) parseObject() (Expression, error) {
+	obj := exprObject{}
+
+	for !p.isPunct("}") {
+		if len(obj) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, p.errorf("expected an object key, found %s", p.tok)
+		}
+
+		key := p.tok.val
+		p.next()
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		val, err := p.parseArg()
+
+		if err != nil {
+			return nil, err
+		}
+
+		obj[key] = val
+	}
+
+	p.next()
+
+	return obj, nil
+}
+
+// formatOperand formats an operand of allof, anyof or not, adding
+// parentheses where the infix form would otherwise be reparsed differently
+func formatOperand(e Expression, inAnyOf bool) string {
+	sl, ok := e.(exprSlice)
+
+	if !ok || len(sl) <= 2 {
+		return e.String()
+	}
+
+	switch sl[0] {
+	case exprString("allof"):
+		if inAnyOf {
+			return e.String()
+		}
+	case exprString("anyof"):
+	default:
+		return e.String()
+	}
+
+	return "(" + e.String() + ")"
+}
+
+func isWord(s string) bool {
+	if s == "" || intRe.MatchString(s) {
+		return false
+	}
+
+	switch s {
+	case "true", "false", "and", "or", "not":
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isWordByte(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// formatArg formats a term argument
+func formatArg(e Expression) string {
+	switch e := e.(type) {
+	case exprString:
+		if isWord(string(e)) {
+			return string(e)
+		}
+
+		b, _ := json.Marshal(string(e))
+		return string(b)
+	case exprInt:
+		return strconv.FormatInt(int64(e), 10)
+	case exprBool:
+		return strconv.FormatBool(bool(e))
+	case exprSlice:
+		parts := make([]string, len(e))
+		for i := range e {
+			parts[i] = formatArg(e[i])
+		}
+
+		return "[" + strings.Join(parts, ", ") + "]"
+	case exprObject:
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = formatArg(exprString(k)) + ": " + formatArg(e[k])
+		}
+
+		return "{" + strings.Join(parts, ", ") + "}"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%v", e)
+}
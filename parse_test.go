@@ -0,0 +1,121 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var parseTests = []struct {
+	expr Expression
+	text string
+}{
+	{True(), `true`},
+	{Suffix("go"), `suffix(go)`},
+	{Suffix("go", "mod"), `suffix([go, mod])`},
+	{AllOf(Suffix("go"), Not(Dirname(CaseSensitive, "vendor"))), `suffix(go) and not dirname(vendor)`},
+	{AnyOf(AllOf(Exists(), Empty()), Type(TypeDirectory)), `exists and empty or type(d)`},
+	{AllOf(AnyOf(Exists(), Empty()), Type(TypeDirectory)), `(exists or empty) and type(d)`},
+	{Not(AnyOf(Exists(), Empty())), `not (exists or empty)`},
+	{AllOf(Exists()), `allof([exists])`},
+	{AllOf(Exists(), AllOf(Empty(), True())), `exists and (empty and true)`},
+	{DirnameDepth(CaseInsensitive, "src/lib", Ge, 2), `idirname(src/lib, [depth, ge, 2])`},
+	{Size(Gt, 50000000), `size(gt, 50000000)`},
+	{Name(CaseSensitive, Wholename, "a b", "true"), `name(["a b", "true"], wholename)`},
+	{mustExpr(SinceClock(mustParseClock("c:1:2:3:4"))), `since("c:1:2:3:4", oclock)`},
+	{
+		MatchWithOptions(CaseSensitive, Basename, "*.go", MatchOptions{IncludeDotFiles: true}),
+		`match(*.go, basename, {includedotfiles: true})`,
+	},
+}
+
+func TestParseExpression(t *testing.T) {
+	for _, test := range parseTests {
+		b, _ := json.Marshal(test.expr)
+		expr, err := ParseExpression(b)
+
+		assert(t, err == nil, "unexpected err parsing %s: %s", b, err)
+		assert(t, reflect.DeepEqual(expr, test.expr), "expected %#v, found %#v", test.expr, expr)
+	}
+}
+
+func TestParseExpressionText(t *testing.T) {
+	for _, test := range parseTests {
+		expr, err := ParseExpressionText(test.text)
+
+		assert(t, err == nil, "unexpected err parsing %s: %s", test.text, err)
+		assert(t, reflect.DeepEqual(expr, test.expr), "%s: expected %#v, found %#v", test.text, test.expr, expr)
+	}
+}
+
+func TestExpressionString(t *testing.T) {
+	for _, test := range parseTests {
+		s := test.expr.String()
+		assert(t, s == test.text, "expected %s, found %s", test.text, s)
+	}
+}
+
+func TestParseExpressionText_Errors(t *testing.T) {
+	tests := []struct {
+		text   string
+		offset int
+	}{
+		{`suffix(go) and`, 14},
+		{`suffix(go`, 9},
+		{`vendor`, 0},
+		{`exists and bogus(x)`, 11},
+		{`name("abc)`, 5},
+		{`exists exists`, 7},
+		{`size(gt, #)`, 9},
+	}
+
+	for _, test := range tests {
+		_, err := ParseExpressionText(test.text)
+		serr, ok := err.(*SyntaxError)
+
+		assert(t, ok, "%s: expected a syntax error, found %v", test.text, err)
+		assert(t, serr.Offset == test.offset, "%s: expected offset %d, found %d (%s)", test.text, test.offset, serr.Offset, serr)
+	}
+}
+
+func TestParseExpressionText_TermErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		path string
+	}{
+		{`allof([bogus])`, "[1][0]"},
+		{`exists and anyof([suffix, go], [bogus, x])`, "[2][2][0]"},
+		{`anyof([exists], x)`, "[2]"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseExpressionText(test.text)
+		serr, ok := err.(*SyntaxError)
+
+		assert(t, ok, "%s: expected a syntax error, found %v", test.text, err)
+		assert(t, serr.Path == test.path, "%s: expected path %q, found %q (%s)", test.text, test.path, serr.Path, serr)
+	}
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	tests := []struct {
+		json string
+		path string
+	}{
+		{`["bogus"]`, "[0]"},
+		{`"suffix"`, ""},
+		{`["allof", ["x"]]`, "[1][0]"},
+		{`["allof", ["suffix", "go"], ["not", ["bogus"]]]`, "[2][1][0]"},
+		{`["allof", ["suffix", "go"], "exists"]`, "[2]"},
+		{`["size", "gt", 1.5]`, "[2]"},
+		{`["suffix",`, ""},
+	}
+
+	for _, test := range tests {
+		_, err := ParseExpression([]byte(test.json))
+		serr, ok := err.(*SyntaxError)
+
+		assert(t, ok, "%s: expected a syntax error, found %v", test.json, err)
+		assert(t, serr.Path == test.path, "%s: expected path %q, found %q (%s)", test.json, test.path, serr.Path, serr)
+	}
+}
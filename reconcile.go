@@ -0,0 +1,197 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A TriggerManifest declares the triggers that should be installed on a
+// root, in the same JSON form that trigger-list returns
+type TriggerManifest struct {
+	Triggers []TriggerOptions `json:"triggers"`
+}
+
+// LoadTriggerManifest decodes a manifest and checks that its trigger
+// names are unique
+func LoadTriggerManifest(r io.Reader) (*TriggerManifest, error) {
+	var m TriggerManifest
+
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+
+	for _, t := range m.Triggers {
+		if t.Name == "" {
+			return nil, fmt.Errorf("trigger manifest: trigger without a name")
+		}
+
+		if seen[t.Name] {
+			return nil, fmt.Errorf("trigger manifest: duplicate trigger %q", t.Name)
+		}
+
+		seen[t.Name] = true
+	}
+
+	return &m, nil
+}
+
+type TriggerOp string
+
+const (
+	TriggerInstall TriggerOp = "install"
+	TriggerUpdate  TriggerOp = "update"
+	TriggerRemove  TriggerOp = "remove"
+)
+
+// A TriggerAction is a single change made by a TriggerPlan
+type TriggerAction struct {
+	Op      TriggerOp
+	Root    string
+	Name    string
+	Trigger *TriggerOptions // nil for TriggerRemove
+}
+
+func (a TriggerAction) String() string {
+	return fmt.Sprintf("%s trigger %s on %s", a.Op, a.Name, a.Root)
+}
+
+// A TriggerPlan is the list of actions needed to bring the triggers on a
+// root in line with a manifest.  An empty plan means nothing needs to change
+type TriggerPlan []TriggerAction
+
+func (p TriggerPlan) String() string {
+	lines := make([]string, len(p))
+	for i := range p {
+		lines[i] = p[i].String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// PlanTriggers compares the triggers installed on root with desired and
+// returns the actions that would reconcile them.  Installed triggers that
+// are not in desired are removed.  Every desired trigger is validated first,
+// so an invalid one fails the plan rather than the apply
+func (c *Client) PlanTriggers(root string, desired []TriggerOptions) (TriggerPlan, error) {
+	for i := range desired {
+		if err := desired[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	installed, err := c.TriggerList(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]TriggerOptions, len(installed))
+	for _, t := range installed {
+		byName[t.Name] = t
+	}
+
+	var plan TriggerPlan
+
+	for i := range desired {
+		t := &desired[i]
+		cur, ok := byName[t.Name]
+
+		if !ok {
+			plan = append(plan, TriggerAction{Op: TriggerInstall, Root: root, Name: t.Name, Trigger: t})
+		} else if same, err := sameTrigger(&cur, t); err != nil {
+			return nil, fmt.Errorf("trigger %s: %s", t.Name, err)
+		} else if !same {
+			plan = append(plan, TriggerAction{Op: TriggerUpdate, Root: root, Name: t.Name, Trigger: t})
+		}
+
+		delete(byName, t.Name)
+	}
+
+	for name := range byName {
+		plan = append(plan, TriggerAction{Op: TriggerRemove, Root: root, Name: name})
+	}
+
+	sort.SliceStable(plan, func(i, j int) bool {
+		return plan[i].Name < plan[j].Name
+	})
+
+	return plan, nil
+}
+
+// ApplyTriggers carries out a plan.  Installing and updating are both done
+// with the trigger command, which replaces an existing trigger of the same name
+func (c *Client) ApplyTriggers(plan TriggerPlan) error {
+	for _, a := range plan {
+		var err error
+
+		switch a.Op {
+		case TriggerInstall, TriggerUpdate:
+			err = c.Trigger(a.Root, a.Trigger)
+		case TriggerRemove:
+			err = c.TriggerDel(a.Root, a.Name)
+		default:
+			err = fmt.Errorf("unknown trigger op %q", a.Op)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %s", a, err)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileTriggers makes the triggers on root match desired and returns
+// the plan that was carried out.  With dryRun, the plan is only computed
+func (c *Client) ReconcileTriggers(root string, desired []TriggerOptions, dryRun bool) (TriggerPlan, error) {
+	plan, err := c.PlanTriggers(root, desired)
+
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	return plan, c.ApplyTriggers(plan)
+}
+
+// sameTrigger compares two trigger definitions by their JSON form, treating
+// an unset stdin as the server default of /dev/null
+func sameTrigger(a, b *TriggerOptions) (bool, error) {
+	normalize := func(t *TriggerOptions) (interface{}, error) {
+		n := *t
+
+		if n.Stdin == nil {
+			n.Stdin = StdinDevNull
+		}
+
+		b, err := json.Marshal(&n)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		err = json.Unmarshal(b, &v)
+
+		return v, err
+	}
+
+	av, err := normalize(a)
+
+	if err != nil {
+		return false, err
+	}
+
+	bv, err := normalize(b)
+
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(av, bv), nil
+}
@@ -0,0 +1,49 @@
+package kovacs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTriggerManifest(t *testing.T) {
+	m, err := LoadTriggerManifest(strings.NewReader(`{"triggers": [
+		{"name": "assets", "command": ["make"], "expression": ["suffix", "css"]},
+		{"name": "lint", "command": ["lint"], "stdin": "NAME_PER_LINE"}
+	]}`))
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(m.Triggers) == 2, "expected 2 triggers, found %d", len(m.Triggers))
+
+	_, err = LoadTriggerManifest(strings.NewReader(`{"triggers": [{"name": "a"}, {"name": "a"}]}`))
+	assert(t, err != nil, "expected a duplicate name error")
+}
+
+func TestSameTrigger(t *testing.T) {
+	a := &TriggerOptions{Name: "a", Command: []string{"make"}, Expression: Suffix("css")}
+	b := &TriggerOptions{Name: "a", Command: []string{"make"}, Expression: Suffix("css"), Stdin: StdinDevNull}
+
+	same, err := sameTrigger(a, b)
+	assert(t, err == nil && same, "expected an unset stdin to equal /dev/null (%v)", err)
+
+	b.Expression = Suffix("js")
+	same, err = sameTrigger(a, b)
+	assert(t, err == nil && !same, "expected different expressions to differ (%v)", err)
+}
+
+func TestPlanTriggers_Invalid(t *testing.T) {
+	c := NewClient(nil)
+
+	_, err := c.PlanTriggers("/repo", []TriggerOptions{{Name: "assets"}})
+	_, ok := err.(*InvalidTriggerError)
+	assert(t, ok, "expected an InvalidTriggerError, found %v", err)
+}
+
+func TestTriggerPlanString(t *testing.T) {
+	plan := TriggerPlan{
+		{Op: TriggerInstall, Root: "/repo", Name: "assets"},
+		{Op: TriggerRemove, Root: "/repo", Name: "old"},
+	}
+
+	expected := "install trigger assets on /repo\nremove trigger old on /repo"
+	assert(t, plan.String() == expected, "expected %q, found %q", expected, plan.String())
+}
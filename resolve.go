@@ -0,0 +1,171 @@
+package kovacs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultRootFiles are the root_files used when the global config sets none
+// https://facebook.github.io/watchman/docs/config.html#root_files
+var DefaultRootFiles = []string{".git", ".hg", ".svn", ".watchmanconfig"}
+
+// DefaultGlobalConfigFile is where watchman reads its global config from
+// when WATCHMAN_CONFIG_FILE is unset
+const DefaultGlobalConfigFile = "/etc/watchman.json"
+
+// LoadGlobalConfig reads the global watchman config at path.  An empty path
+// uses WATCHMAN_CONFIG_FILE, falling back to DefaultGlobalConfigFile.  A
+// missing file is an empty config, as it is for the server
+func LoadGlobalConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("WATCHMAN_CONFIG_FILE")
+	}
+
+	if path == "" {
+		path = DefaultGlobalConfigFile
+	}
+
+	f, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadConfig(f)
+}
+
+// A RootCandidate is a directory considered while resolving a project root
+type RootCandidate struct {
+	Dir      string
+	Accepted bool
+	Reason   string
+}
+
+func (c RootCandidate) String() string {
+	verdict := "rejected"
+	if c.Accepted {
+		verdict = "accepted"
+	}
+
+	return c.Dir + ": " + verdict + ": " + c.Reason
+}
+
+// A RootResolution is the outcome of ResolveProjectRoot.  Root and
+// RelativePath match the watch and relative_path that watch-project would
+// return
+type RootResolution struct {
+	Root         string
+	RelativePath string
+	Candidates   []RootCandidate // in the order they were considered
+}
+
+// A RootRestrictedError is returned when the resolved root contains none of
+// the files required by enforce_root_files or root_restrict_files
+type RootRestrictedError struct {
+	Dir   string
+	Files []string
+}
+
+func (e *RootRestrictedError) Error() string {
+	return "none of the required files [" + strings.Join(e.Files, ", ") + "] are present in " + e.Dir
+}
+
+// ResolveProjectRoot works out which root watch-project would choose for
+// dir under the global config conf, which may be nil.  It walks up from dir
+// and picks the nearest directory containing one of the root files, or dir
+// itself if there is none.  The root files are root_files, or the legacy
+// root_restrict_files when root_files is unset.  If enforce_root_files is
+// set or root_restrict_files is used and the chosen root contains none of
+// them, the resolution is returned along with a *RootRestrictedError
+func ResolveProjectRoot(dir string, conf *Config) (*RootResolution, error) {
+	if conf == nil {
+		conf = &Config{}
+	}
+
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, err
+	}
+
+	rootFiles, enforce := conf.effectiveRootFiles()
+
+	var (
+		res  = &RootResolution{Root: dir}
+		cur  = dir
+		done bool
+	)
+
+	for !done {
+		if f := firstPresent(cur, rootFiles); f != "" {
+			res.Candidates = append(res.Candidates, RootCandidate{cur, true, "contains " + f})
+			res.Root = cur
+			break
+		}
+
+		res.Candidates = append(res.Candidates, RootCandidate{cur, false, "contains none of the root_files"})
+
+		parent := filepath.Dir(cur)
+		done, cur = parent == cur, parent
+	}
+
+	if !res.Candidates[len(res.Candidates)-1].Accepted {
+		res.Candidates = append(res.Candidates, RootCandidate{dir, true, "no project root found, so the directory itself is watched"})
+	}
+
+	rel, err := filepath.Rel(res.Root, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rel != "." {
+		res.RelativePath = filepath.ToSlash(rel)
+	}
+
+	if enforce && firstPresent(res.Root, rootFiles) == "" {
+		last := &res.Candidates[len(res.Candidates)-1]
+		last.Accepted = false
+		last.Reason = "contains none of the root_files, which the global config enforces"
+
+		return res, &RootRestrictedError{Dir: res.Root, Files: rootFiles}
+	}
+
+	return res, nil
+}
+
+// effectiveRootFiles returns the root files that watchman looks for and
+// whether a root must contain one of them.  root_files takes precedence over
+// the legacy root_restrict_files, which implies enforcement
+func (conf *Config) effectiveRootFiles() (files []string, enforce bool) {
+	switch {
+	case len(conf.RootFiles) > 0:
+		return conf.RootFiles, conf.EnforceRootFiles
+	case len(conf.RootRestrictFiles) > 0:
+		return conf.RootRestrictFiles, true
+	}
+
+	return DefaultRootFiles, conf.EnforceRootFiles
+}
+
+// firstPresent returns the first of names that exists in dir
+func firstPresent(dir string, names []string) string {
+	for _, name := range names {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return name
+		}
+	}
+
+	return ""
+}
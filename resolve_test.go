@@ -0,0 +1,66 @@
+package kovacs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveProjectRoot(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "kovacs")
+	assert(t, err == nil, "unexpected tempdir err: %s", err)
+	defer os.RemoveAll(tmp)
+
+	tmp, _ = filepath.EvalSymlinks(tmp)
+
+	var (
+		project = filepath.Join(tmp, "project")
+		sub     = filepath.Join(project, "a", "b")
+		bare    = filepath.Join(tmp, "bare")
+	)
+
+	os.MkdirAll(filepath.Join(project, ".hg"), 0755)
+	os.MkdirAll(sub, 0755)
+	os.MkdirAll(bare, 0755)
+
+	res, err := ResolveProjectRoot(sub, nil)
+	assert(t, err == nil, "unexpected resolve err: %s", err)
+	assert(t, res.Root == project, "expected root %s, found %s", project, res.Root)
+	assert(t, res.RelativePath == "a/b", "expected relative path a/b, found %s", res.RelativePath)
+	assert(t, len(res.Candidates) == 3, "expected 3 candidates, found %v", res.Candidates)
+	assert(t, res.Candidates[2].String() == project+": accepted: contains .hg", "unexpected candidate %s", res.Candidates[2])
+
+	res, err = ResolveProjectRoot(sub, &Config{RootFiles: []string{"b"}})
+	assert(t, err == nil, "unexpected resolve err: %s", err)
+	assert(t, res.Root == filepath.Join(project, "a"), "unexpected root %s", res.Root)
+
+	res, err = ResolveProjectRoot(bare, nil)
+	assert(t, err == nil, "unexpected resolve err: %s", err)
+	assert(t, res.Root == bare && res.RelativePath == "", "unexpected resolution %+v", res)
+
+	res, err = ResolveProjectRoot(bare, &Config{EnforceRootFiles: true})
+	_, ok := err.(*RootRestrictedError)
+	assert(t, ok, "expected *RootRestrictedError, found %v", err)
+	assert(t, !res.Candidates[len(res.Candidates)-1].Accepted, "expected the fallback to be rejected")
+
+	_, err = ResolveProjectRoot(sub, &Config{RootRestrictFiles: []string{".git"}})
+	assert(t, err != nil, "expected a restricted root error")
+
+	// the legacy root_restrict_files is also the set the walk looks for
+	var (
+		parent = filepath.Join(tmp, "parent")
+		repo   = filepath.Join(parent, "repo")
+	)
+
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(parent, ".watchmanconfig"), []byte("{}"), 0644)
+
+	res, err = ResolveProjectRoot(repo, &Config{RootRestrictFiles: []string{".watchmanconfig"}})
+	assert(t, err == nil, "unexpected resolve err: %s", err)
+	assert(t, res.Root == parent && res.RelativePath == "repo", "unexpected resolution %+v", res)
+
+	res, err = ResolveProjectRoot(repo, &Config{RootFiles: []string{".git"}, RootRestrictFiles: []string{".watchmanconfig"}})
+	assert(t, err == nil, "unexpected resolve err: %s", err)
+	assert(t, res.Root == repo, "expected root_files to take precedence, found %s", res.Root)
+}
@@ -0,0 +1,184 @@
+package kovacs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A Root is a watched root as returned by Watch or WatchProject.  When
+// RelativePath is set, commands run through the Root are scoped to that
+// directory within the root by applying it as their relative_root, and
+// file names in their results are relative to it
+type Root struct {
+	c *Client
+
+	Path         string // the root that watchman is watching
+	RelativePath string // the directory within Path that was requested
+	Warning      string // a warning returned when the root was watched
+}
+
+// ErrOutsideRoot is returned by Rel for paths outside of the root's directory
+var ErrOutsideRoot = errors.New("path is outside of the watched root")
+
+// Dir returns the directory that the root's commands are scoped to
+func (r *Root) Dir() string {
+	return filepath.Join(r.Path, filepath.FromSlash(r.RelativePath))
+}
+
+// Abs returns the filesystem path of name, a file name as returned by the
+// root's commands
+func (r *Root) Abs(name string) string {
+	return filepath.Join(r.Dir(), filepath.FromSlash(name))
+}
+
+// Rel returns p as a slash separated name relative to Dir, suitable for use
+// in expressions and query paths.  Relative paths are resolved against the
+// current working directory, and symlinks are resolved as watchman does for
+// the root, so /tmp/x matches a root at /private/tmp
+func (r *Root) Rel(p string) (string, error) {
+	p, err := filepath.Abs(p)
+
+	if err != nil {
+		return "", err
+	}
+
+	if p, err = evalSymlinks(p); err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(r.Dir(), p)
+
+	if err != nil {
+		return "", err
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", ErrOutsideRoot
+	}
+
+	if rel == "." {
+		return "", nil
+	}
+
+	return rel, nil
+}
+
+// evalSymlinks resolves the symlinks in p.  Deleted files are still named in
+// query results, so when p does not exist its nearest existing parent is
+// resolved instead and the rest of p is kept as is
+func evalSymlinks(p string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(p)
+
+	if err == nil {
+		return resolved, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	dir := filepath.Dir(p)
+
+	if dir == p {
+		return p, nil
+	}
+
+	if dir, err = evalSymlinks(dir); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.Base(p)), nil
+}
+
+// relativeRoot joins rel onto the root's relative path
+func (r *Root) relativeRoot(rel string) string {
+	if r.RelativePath == "" {
+		return rel
+	}
+
+	return path.Join(r.RelativePath, rel)
+}
+
+// Clock returns the current clock of the root
+func (r *Root) Clock() (Clock, error) {
+	return r.c.Clock(r.Path)
+}
+
+// Query runs a query on the root
+func (r *Root) Query(conf QueryOptions) ([]File, Clock, error) {
+	conf.RelativeRoot = r.relativeRoot(conf.RelativeRoot)
+	return r.c.Query(r.Path, conf)
+}
+
+// Since returns the files changed since clock.  The since command has no
+// relative_root, so patterns are treated as wholename globs and the changes
+// are found with a query instead
+func (r *Root) Since(clock Clock, patterns ...string) ([]File, Clock, error) {
+	if clock.IsZero() {
+		return nil, Clock{}, ErrZeroClock
+	}
+
+	conf := QueryOptions{Since: clock.String()}
+
+	if len(patterns) > 0 {
+		exprs := make([]Expression, len(patterns))
+		for i, p := range patterns {
+			exprs[i] = Match(CaseSensitive, Wholename, p)
+		}
+
+		conf.Expression = AnyOf(exprs...)
+	}
+
+	return r.Query(conf)
+}
+
+// Subscribe subscribes to changes on the root
+func (r *Root) Subscribe(name string, opts *SubscriptionOptions) error {
+	var o SubscriptionOptions
+
+	if opts != nil {
+		o = *opts
+	}
+
+	o.RelativeRoot = r.relativeRoot(o.RelativeRoot)
+
+	return r.c.Subscribe(r.Path, name, &o)
+}
+
+// Unsubscribe cancels a subscription made with Subscribe
+func (r *Root) Unsubscribe(name string) error {
+	return r.c.Unsubscribe(r.Path, name)
+}
+
+// Trigger installs a trigger on the root
+func (r *Root) Trigger(opts *TriggerOptions) error {
+	if opts == nil {
+		return r.c.Trigger(r.Path, nil)
+	}
+
+	o := *opts
+	o.RelativeRoot = r.relativeRoot(o.RelativeRoot)
+
+	return r.c.Trigger(r.Path, &o)
+}
+
+// StateEnter asserts the named state on the root
+func (r *Root) StateEnter(opts StateOptions) error {
+	return r.c.StateEnter(r.Path, opts)
+}
+
+// StateLeave vacates a state asserted with StateEnter
+func (r *Root) StateLeave(opts StateOptions) error {
+	return r.c.StateLeave(r.Path, opts)
+}
+
+// Unwatch stops watching the root.  This removes the whole watch, including
+// for any other Roots that share it
+func (r *Root) Unwatch() error {
+	return r.c.WatchDel(r.Path)
+}
@@ -0,0 +1,74 @@
+package kovacs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRootRel(t *testing.T) {
+	r := &Root{Path: "/src/project", RelativePath: "sub/dir"}
+
+	tests := []struct {
+		path string
+		rel  string
+		err  error
+	}{
+		{"/src/project/sub/dir", "", nil},
+		{"/src/project/sub/dir/a/b.go", "a/b.go", nil},
+		{"/src/project/sub/dir/../x", "", ErrOutsideRoot},
+		{"/src/project/other", "", ErrOutsideRoot},
+	}
+
+	for _, tt := range tests {
+		rel, err := r.Rel(tt.path)
+		assert(t, err == tt.err, "%s: expected err %v, found %v", tt.path, tt.err, err)
+		assert(t, rel == tt.rel, "%s: expected %q, found %q", tt.path, tt.rel, rel)
+	}
+
+	abs := r.Abs("a/b.go")
+	assert(t, abs == "/src/project/sub/dir/a/b.go", "unexpected abs path %s", abs)
+}
+
+func TestRootRel_Symlink(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "kovacs")
+	assert(t, err == nil, "unexpected err: %s", err)
+	defer os.RemoveAll(tmp)
+
+	tmp, _ = filepath.EvalSymlinks(tmp)
+	real := filepath.Join(tmp, "real")
+	link := filepath.Join(tmp, "link")
+
+	os.MkdirAll(filepath.Join(real, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(real, "sub", "a.go"), nil, 0644)
+	assert(t, os.Symlink(real, link) == nil, "symlink failed")
+
+	r := &Root{Path: real}
+
+	for path, expected := range map[string]string{
+		filepath.Join(link, "sub", "a.go"):       "sub/a.go",
+		filepath.Join(link, "sub", "deleted.go"): "sub/deleted.go",
+		filepath.Join(link, "gone", "b.go"):      "gone/b.go",
+	} {
+		rel, err := r.Rel(path)
+		assert(t, err == nil, "%s: unexpected err: %s", path, err)
+		assert(t, rel == expected, "%s: expected %q, found %q", path, expected, rel)
+	}
+}
+
+func TestRootRelativeRoot(t *testing.T) {
+	r := &Root{Path: "/src/project"}
+	assert(t, r.relativeRoot("a") == "a", "unexpected relative root %s", r.relativeRoot("a"))
+
+	r.RelativePath = "sub"
+	assert(t, r.relativeRoot("") == "sub", "unexpected relative root %s", r.relativeRoot(""))
+	assert(t, r.relativeRoot("a") == "sub/a", "unexpected relative root %s", r.relativeRoot("a"))
+}
+
+func TestRootTrigger_Nil(t *testing.T) {
+	r := &Root{c: NewClient(nil), Path: "/src/project", RelativePath: "sub"}
+
+	_, ok := r.Trigger(nil).(*InvalidTriggerError)
+	assert(t, ok, "expected an InvalidTriggerError for nil options")
+}
@@ -0,0 +1,181 @@
+package kovacs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// a fileStream receives the elements of a response's files array one at a
+// time rather than having them unmarshaled into the request dest
+type fileStream struct {
+	fn  func(json.RawMessage) error
+	err error // the first error returned from fn
+
+	// decode files directly off the socket.  This is only safe when
+	// no subscription events, which also carry files, can arrive, as a
+	// pdu is only known to be unilateral once its keys have been read.
+	// Otherwise the files array is buffered whole before it is streamed
+	direct bool
+}
+
+func (s *fileStream) send(raw json.RawMessage) {
+	if s.err != nil {
+		return
+	}
+
+	s.err = s.fn(raw)
+}
+
+// streamSlot holds the stream of the request that is currently in flight.
+// It is set by the select loop and read by the socket reader
+type streamSlot struct {
+	mu sync.Mutex
+	s  *fileStream
+}
+
+func (sl *streamSlot) get() *fileStream {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return sl.s
+}
+
+func (sl *streamSlot) set(s *fileStream) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.s = s
+}
+
+// readResp reads a single pdu off of dec.  The active stream is only looked
+// up after the opening brace has been read, so a response can never be
+// matched against a stream registered after it arrived
+func readResp(dec *json.Decoder, slot *streamSlot) (*resp, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var (
+		s          = slot.get()
+		buf        bytes.Buffer
+		files      json.RawMessage
+		unilateral bool
+	)
+
+	buf.WriteByte('{')
+
+	for dec.More() {
+		tok, err := dec.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := tok.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", tok)
+		}
+
+		if key == "files" && s != nil {
+			if s.direct && !unilateral {
+				if err := streamArray(dec, s); err != nil {
+					return nil, err
+				}
+			} else if err := dec.Decode(&files); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		var raw json.RawMessage
+
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "log", "subscription", "unilateral":
+			unilateral = true
+		}
+
+		writeField(&buf, key, raw)
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if s != nil {
+		if unilateral {
+			if files != nil {
+				writeField(&buf, "files", files)
+			}
+		} else {
+			if files != nil {
+				if err := streamArray(json.NewDecoder(bytes.NewReader(files)), s); err != nil {
+					return nil, err
+				}
+			}
+
+			slot.set(nil)
+		}
+	}
+
+	buf.WriteByte('}')
+
+	var r resp
+
+	if err := r.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// streamArray decodes a json array one element at a time
+func streamArray(dec *json.Decoder, s *fileStream) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		s.send(raw)
+	}
+
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+
+	if err != nil {
+		return err
+	}
+
+	if tok != d {
+		return fmt.Errorf("expected %s, found %v", d, tok)
+	}
+
+	return nil
+}
+
+func writeField(buf *bytes.Buffer, key string, raw json.RawMessage) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+
+	k, _ := json.Marshal(key)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(raw)
+}
@@ -0,0 +1,65 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReadResp(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"log": "hi", "files": ["x"]} {"version": "4.9.0", "files": [{"name": "a"}, {"name": "b"}], "clock": "c:1:2:3:4"}`))
+
+	var (
+		names []string
+		slot  streamSlot
+	)
+
+	slot.set(&fileStream{direct: true, fn: func(raw json.RawMessage) error {
+		var f File
+		err := json.Unmarshal(raw, &f)
+		names = append(names, f.Name)
+		return err
+	}})
+
+	r, err := readResp(dec, &slot)
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, r.Log != nil && *r.Log == "hi", "expected log pdu, found %s", r.Raw)
+	assert(t, len(names) == 0, "unexpected streamed files %v", names)
+	assert(t, slot.get() != nil, "stream cleared by unilateral pdu")
+
+	r, err = readResp(dec, &slot)
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, string(r.Raw) == `{"version":"4.9.0","clock":"c:1:2:3:4"}`, "unexpected raw response %s", r.Raw)
+	assert(t, strings.Join(names, ",") == "a,b", "unexpected streamed files %v", names)
+	assert(t, slot.get() == nil, "stream not cleared by response")
+}
+
+func TestReadResp_Subscription(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"files": ["a"], "subscription": "sub"}`))
+
+	var slot streamSlot
+	slot.set(&fileStream{fn: func(raw json.RawMessage) error {
+		t.Fatalf("unexpected streamed file %s", raw)
+		return nil
+	}})
+
+	r, err := readResp(dec, &slot)
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, string(r.Raw) == `{"subscription":"sub","files":["a"]}`, "unexpected raw response %s", r.Raw)
+}
+
+func TestClientSubs(t *testing.T) {
+	c := NewClient(nil)
+	assert(t, !c.hasSubs(), "unexpected subscriptions")
+
+	c.addSub("/a", "x")
+	c.addSub("/a", "x")
+	c.addSub("/b", "x")
+	assert(t, c.hasSubs(), "expected subscriptions")
+
+	c.removeSub("/a", "x")
+	assert(t, c.hasSubs(), "expected subscriptions on /b")
+
+	c.removeSub("/b", "x")
+	assert(t, !c.hasSubs(), "unexpected subscriptions")
+}
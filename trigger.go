@@ -0,0 +1,102 @@
+package kovacs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// An InvalidTriggerError describes a trigger definition that the server
+// would reject
+type InvalidTriggerError struct {
+	Trigger string
+	Field   string
+	Reason  string
+}
+
+func (e *InvalidTriggerError) Error() string {
+	return fmt.Sprintf("invalid trigger %q: %s %s", e.Trigger, e.Field, e.Reason)
+}
+
+// Validate checks the trigger definition before it is sent to the server
+func (t *TriggerOptions) Validate() error {
+	if t == nil {
+		return &InvalidTriggerError{Field: "options", Reason: "are required"}
+	}
+
+	invalid := func(field, format string, args ...interface{}) error {
+		return &InvalidTriggerError{Trigger: t.Name, Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if t.Name == "" {
+		return invalid("name", "is required")
+	}
+
+	if len(t.Command) == 0 {
+		return invalid("command", "is required")
+	}
+
+	if t.Expression != nil {
+		if err := checkTerm(t.Expression); err != nil {
+			return invalid("expression", "%s", err)
+		}
+	}
+
+	switch stdin := t.Stdin.(type) {
+	case nil:
+	case stdinString:
+		if stdin != StdinDevNull && stdin != StdinNamePerLine {
+			return invalid("stdin", "%q is not /dev/null or NAME_PER_LINE", string(stdin))
+		}
+	case StdinArray:
+		if len(stdin) == 0 {
+			return invalid("stdin", "field list is empty")
+		}
+
+		for _, f := range stdin {
+			if !fileFields[f] {
+				return invalid("stdin", "has unknown field %q", f)
+			}
+		}
+	}
+
+	if t.MaxFilesStdin < 0 {
+		return invalid("max_files_stdin", "must not be negative")
+	}
+
+	if t.MaxFilesStdin > 0 && (t.Stdin == nil || t.Stdin == StdinDevNull) {
+		return invalid("max_files_stdin", "requires stdin to receive the file list")
+	}
+
+	if err := t.Stdout.validate(); err != nil {
+		return invalid("stdout", "%s", err)
+	}
+
+	if err := t.Stderr.validate(); err != nil {
+		return invalid("stderr", "%s", err)
+	}
+
+	if filepath.IsAbs(t.RelativeRoot) {
+		return invalid("relative_root", "must be relative to the root")
+	}
+
+	return nil
+}
+
+func (r OutputRedirect) validate() error {
+	if r == "" {
+		return nil
+	}
+
+	file := strings.TrimPrefix(strings.TrimPrefix(string(r), ">"), ">")
+
+	if len(file) == len(r) {
+		return fmt.Errorf("%q must start with > or >>", string(r))
+	}
+
+	if file == "" {
+		return fmt.Errorf("%q has no file name", string(r))
+	}
+
+	return nil
+}
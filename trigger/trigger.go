@@ -0,0 +1,163 @@
+// Package trigger decodes the context that watchman passes to a program
+// run by a trigger: the WATCHMAN_* environment variables and the list of
+// changed files delivered on stdin or appended to the command line.
+// https://facebook.github.io/watchman/docs/cmd/trigger.html
+package trigger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jonasi/kovacs"
+)
+
+// Config describes how the trigger that runs the program was defined
+type Config struct {
+	// Stdin is the stdin mode of the trigger definition.  A nil Stdin is
+	// the same as kovacs.StdinDevNull
+	Stdin kovacs.StdinType
+
+	// Args holds the file names watchman appended to the command line when
+	// the trigger was defined with append_files, typically os.Args[n:] where
+	// n is the number of arguments in the trigger command
+	Args []string
+}
+
+// An Invocation is the context of a single trigger run
+type Invocation struct {
+	Root         string // WATCHMAN_ROOT, the watched root
+	Trigger      string // WATCHMAN_TRIGGER, the name of the trigger
+	RelativeRoot string // WATCHMAN_RELATIVE_ROOT, set when the trigger has a relative root
+	Sock         string // WATCHMAN_SOCK, the socket of the server that ran the trigger
+
+	Since kovacs.Clock // WATCHMAN_SINCE, the clock of the previous run
+	Clock kovacs.Clock // WATCHMAN_CLOCK, the clock at which the files were collected
+
+	// Files are the changed files.  They are decoded from stdin when the
+	// trigger delivers files there, and from Config.Args otherwise.  Only
+	// Name is set for files delivered as names
+	Files []kovacs.File
+
+	// Overflow is true when watchman truncated the file list because it
+	// exceeded max_files_stdin or the command line length limit.  The
+	// program should then treat every file in the root as changed
+	Overflow bool
+}
+
+// Read decodes the invocation of the current process
+func Read(conf Config) (*Invocation, error) {
+	return Decode(conf, os.Getenv, os.Stdin)
+}
+
+// Decode decodes an invocation from the environment lookup function
+// getenv and the program's stdin r
+func Decode(conf Config, getenv func(string) string, r io.Reader) (*Invocation, error) {
+	inv := &Invocation{
+		Root:         getenv("WATCHMAN_ROOT"),
+		Trigger:      getenv("WATCHMAN_TRIGGER"),
+		RelativeRoot: getenv("WATCHMAN_RELATIVE_ROOT"),
+		Sock:         getenv("WATCHMAN_SOCK"),
+		Overflow:     getenv("WATCHMAN_FILES_OVERFLOW") == "true",
+	}
+
+	if inv.Root == "" {
+		return nil, errors.New("WATCHMAN_ROOT is not set, the program was not run by a watchman trigger")
+	}
+
+	for _, c := range []struct {
+		env  string
+		dest *kovacs.Clock
+	}{
+		{"WATCHMAN_SINCE", &inv.Since},
+		{"WATCHMAN_CLOCK", &inv.Clock},
+	} {
+		s := getenv(c.env)
+
+		if s == "" {
+			continue
+		}
+
+		clock, err := kovacs.ParseClock(s)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", c.env, err)
+		}
+
+		*c.dest = clock
+	}
+
+	var err error
+
+	switch mode := conf.Stdin.(type) {
+	case kovacs.StdinArray:
+		inv.Files, err = decodeArray(mode, r)
+	default:
+		if mode == kovacs.StdinNamePerLine {
+			inv.Files, err = decodeLines(r)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.Files == nil {
+		for _, name := range conf.Args {
+			inv.Files = append(inv.Files, kovacs.File{Name: name})
+		}
+	}
+
+	return inv, nil
+}
+
+// decodeLines decodes the NAME_PER_LINE format
+func decodeLines(r io.Reader) ([]kovacs.File, error) {
+	var (
+		files []kovacs.File
+		sc    = bufio.NewScanner(r)
+	)
+
+	for sc.Scan() {
+		if name := sc.Text(); name != "" {
+			files = append(files, kovacs.File{Name: name})
+		}
+	}
+
+	return files, sc.Err()
+}
+
+// decodeArray decodes the JSON array format.  As with queries, requesting a
+// single field produces an array of bare values instead of objects
+func decodeArray(fields kovacs.StdinArray, r io.Reader) ([]kovacs.File, error) {
+	var raw []json.RawMessage
+
+	if err := json.NewDecoder(r).Decode(&raw); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding stdin: %s", err)
+	}
+
+	files := make([]kovacs.File, len(raw))
+
+	for i := range raw {
+		obj := raw[i]
+
+		if len(fields) == 1 {
+			b, err := json.Marshal(map[string]json.RawMessage{fields[0]: raw[i]})
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj = b
+		}
+
+		if err := json.Unmarshal(obj, &files[i]); err != nil {
+			return nil, fmt.Errorf("decoding stdin: %s", err)
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,99 @@
+package trigger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonasi/kovacs"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+var baseEnv = map[string]string{
+	"WATCHMAN_ROOT":    "/repo",
+	"WATCHMAN_TRIGGER": "assets",
+	"WATCHMAN_SINCE":   "c:1500000000:123:1:10",
+	"WATCHMAN_CLOCK":   "c:1500000000:123:1:12",
+}
+
+func TestDecode_NamePerLine(t *testing.T) {
+	inv, err := Decode(Config{Stdin: kovacs.StdinNamePerLine}, env(baseEnv), strings.NewReader("a.css\nsub/b.css\n"))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if inv.Root != "/repo" || inv.Trigger != "assets" || inv.Overflow {
+		t.Fatalf("unexpected invocation %+v", inv)
+	}
+
+	if cmp, _ := inv.Since.Compare(inv.Clock); cmp != -1 {
+		t.Fatalf("expected since before clock, found %s and %s", inv.Since, inv.Clock)
+	}
+
+	if len(inv.Files) != 2 || inv.Files[1].Name != "sub/b.css" {
+		t.Fatalf("unexpected files %+v", inv.Files)
+	}
+}
+
+func TestDecode_Array(t *testing.T) {
+	stdin := `[{"name": "a.css", "size": 10, "exists": true}, {"name": "b.css", "size": 0, "exists": false}]`
+	inv, err := Decode(Config{Stdin: kovacs.StdinArray{"name", "size", "exists"}}, env(baseEnv), strings.NewReader(stdin))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(inv.Files) != 2 || inv.Files[0].Size != 10 || inv.Files[1].Exists {
+		t.Fatalf("unexpected files %+v", inv.Files)
+	}
+
+	inv, err = Decode(Config{Stdin: kovacs.StdinArray{"name"}}, env(baseEnv), strings.NewReader(`["a.css", "b.css"]`))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(inv.Files) != 2 || inv.Files[1].Name != "b.css" {
+		t.Fatalf("unexpected files %+v", inv.Files)
+	}
+
+	stdin = `[{"name": "a", "mtime_ms": 1500, "symlink_target": "b", "content.sha1hex": "da39a3ee"}]`
+	fields := kovacs.StdinArray{"name", "mtime_ms", "symlink_target", "content.sha1hex"}
+	inv, err = Decode(Config{Stdin: fields}, env(baseEnv), strings.NewReader(stdin))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if f := inv.Files[0]; f.MtimeMs != 1500 || f.SymlinkTarget != "b" || f.ContentSHA1Hex.Hex != "da39a3ee" {
+		t.Fatalf("unexpected file %+v", f)
+	}
+}
+
+func TestDecode_Args(t *testing.T) {
+	vars := map[string]string{"WATCHMAN_ROOT": "/repo", "WATCHMAN_FILES_OVERFLOW": "true"}
+	inv, err := Decode(Config{Args: []string{"a.css", "b.css"}}, env(vars), strings.NewReader(""))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if !inv.Overflow || len(inv.Files) != 2 || inv.Files[0].Name != "a.css" {
+		t.Fatalf("unexpected invocation %+v", inv)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	if _, err := Decode(Config{}, env(nil), strings.NewReader("")); err == nil {
+		t.Fatalf("expected an error without WATCHMAN_ROOT")
+	}
+
+	vars := map[string]string{"WATCHMAN_ROOT": "/repo", "WATCHMAN_CLOCK": "bogus"}
+
+	if _, err := Decode(Config{}, env(vars), strings.NewReader("")); err == nil {
+		t.Fatalf("expected an error for an invalid clock")
+	}
+}
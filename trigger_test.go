@@ -0,0 +1,57 @@
+package kovacs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTriggerOptionsValidate(t *testing.T) {
+	valid := TriggerOptions{
+		Name:          "assets",
+		Command:       []string{"make"},
+		Expression:    Suffix("css"),
+		Stdin:         StdinArray{"name", "size"},
+		Stdout:        RedirectAppend("/tmp/out.log"),
+		Stderr:        RedirectTruncate("/tmp/err.log"),
+		MaxFilesStdin: 10,
+	}
+
+	assert(t, valid.Validate() == nil, "unexpected err: %s", valid.Validate())
+
+	tests := []struct {
+		field  string
+		modify func(*TriggerOptions)
+	}{
+		{"name", func(o *TriggerOptions) { o.Name = "" }},
+		{"command", func(o *TriggerOptions) { o.Command = nil }},
+		{"expression", func(o *TriggerOptions) { o.Expression = exprSlice{exprString("bogus")} }},
+		{"stdin", func(o *TriggerOptions) { o.Stdin = StdinArray{"name", "sha1"} }},
+		{"stdin", func(o *TriggerOptions) { o.Stdin = StdinArray{} }},
+		{"stdin", func(o *TriggerOptions) { o.Stdin = stdinString("/dev/zero") }},
+		{"max_files_stdin", func(o *TriggerOptions) { o.MaxFilesStdin = -1 }},
+		{"max_files_stdin", func(o *TriggerOptions) { o.Stdin = StdinDevNull }},
+		{"stdout", func(o *TriggerOptions) { o.Stdout = "/tmp/out.log" }},
+		{"stderr", func(o *TriggerOptions) { o.Stderr = ">>" }},
+		{"relative_root", func(o *TriggerOptions) { o.RelativeRoot = "/abs" }},
+	}
+
+	for _, test := range tests {
+		opts := valid
+		test.modify(&opts)
+
+		err, ok := opts.Validate().(*InvalidTriggerError)
+		assert(t, ok, "%s: expected an InvalidTriggerError, found %v", test.field, opts.Validate())
+		assert(t, err.Field == test.field, "expected an error for %s, found %s", test.field, err)
+	}
+
+	var nilOpts *TriggerOptions
+	_, ok := nilOpts.Validate().(*InvalidTriggerError)
+	assert(t, ok, "expected an InvalidTriggerError for nil options, found %v", nilOpts.Validate())
+}
+
+func TestTriggerOptionsMarshal(t *testing.T) {
+	b, err := json.Marshal(&TriggerOptions{Name: "lint", Command: []string{"lint"}})
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, string(b) == `{"name":"lint","command":["lint"]}`, "unexpected json %s", b)
+}
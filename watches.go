@@ -0,0 +1,90 @@
+package kovacs
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrWatchNotHeld is returned by ReleaseWatch when holder has not acquired
+// the root
+var ErrWatchNotHeld = errors.New("watch is not held")
+
+// AcquireWatch watches dir on behalf of holder and returns its Root.  Each
+// call must be paired with a ReleaseWatch of the returned root's Path; the
+// watch is only removed once every acquisition has been released.  Roots
+// removed with WatchDel or Root.Unwatch bypass this accounting
+func (c *Client) AcquireWatch(dir, holder string) (*Root, error) {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+
+	root, err := c.Watch(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.watches == nil {
+		c.watches = map[string]map[string]int{}
+	}
+
+	holders := c.watches[root.Path]
+
+	if holders == nil {
+		holders = map[string]int{}
+		c.watches[root.Path] = holders
+	}
+
+	holders[holder]++
+
+	return root, nil
+}
+
+// ReleaseWatch releases one acquisition of root by holder, issuing a
+// watch-del once no holders remain
+func (c *Client) ReleaseWatch(root, holder string) error {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+
+	holders := c.watches[root]
+
+	if holders[holder] == 0 {
+		return ErrWatchNotHeld
+	}
+
+	if holders[holder]--; holders[holder] == 0 {
+		delete(holders, holder)
+	}
+
+	if len(holders) > 0 {
+		return nil
+	}
+
+	delete(c.watches, root)
+
+	return c.WatchDel(root)
+}
+
+// WatchHolders returns the holders of each root acquired with AcquireWatch.
+// A holder that acquired a root more than once is listed once per
+// acquisition
+func (c *Client) WatchHolders() map[string][]string {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+
+	res := make(map[string][]string, len(c.watches))
+
+	for root, holders := range c.watches {
+		var names []string
+
+		for name, n := range holders {
+			for i := 0; i < n; i++ {
+				names = append(names, name)
+			}
+		}
+
+		sort.Strings(names)
+		res[root] = names
+	}
+
+	return res
+}
@@ -0,0 +1,493 @@
+package kovacs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This is a port of the wildmatch implementation that watchman vendors from
+// git, including watchman's WM_PERIOD extension.  It is used by the local
+// evaluator for the match term and by Glob
+
+type wmFlags int
+
+const (
+	wmCaseFold wmFlags = 1 << iota // case insensitive
+	wmPathname                     // * and ? do not match /
+	wmPeriod                       // a leading period must be matched explicitly
+	wmNoEscape                     // backslash is a literal character
+)
+
+const (
+	wmMatch = iota
+	wmNoMatch
+	wmAbortAll
+	wmAbortToStarStar
+)
+
+func matchFlags(cs CaseSensitivity, scope ExpressionScope, opts MatchOptions) wmFlags {
+	var flags wmFlags
+
+	if cs == CaseInsensitive {
+		flags |= wmCaseFold
+	}
+
+	if scope == Wholename {
+		flags |= wmPathname
+	}
+
+	if !opts.IncludeDotFiles {
+		flags |= wmPeriod
+	}
+
+	if opts.NoEscape {
+		flags |= wmNoEscape
+	}
+
+	return flags
+}
+
+// WildMatch reports whether name matches pattern with the semantics of the
+// match expression.  With the Wholename scope, * and ? do not match a slash
+// and ** matches across directories.  The caller is responsible for passing
+// the basename of a file for the Basename scope
+// https://facebook.github.io/watchman/docs/expr/match.html
+func WildMatch(pattern, name string, cs CaseSensitivity, scope ExpressionScope, opts MatchOptions) bool {
+	return wildmatch(pattern, name, matchFlags(cs, scope, opts))
+}
+
+// Glob returns the names that match pattern, using the semantics of the glob
+// query generator: the pattern is matched against the whole name, * and ? do
+// not match a slash, ** matches any number of directories and a leading period
+// in a path component must be matched explicitly
+func Glob(pattern string, names []string) ([]string, error) {
+	if err := ValidateGlob(pattern); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+
+	for _, name := range names {
+		if wildmatch(pattern, name, wmPathname|wmPeriod) {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, nil
+}
+
+// ValidateGlob returns an error if pattern is malformed, for example if it
+// has an unterminated character class.  Malformed patterns never match
+func ValidateGlob(pattern string) error {
+	for p := 0; p < len(pattern); p++ {
+		switch pattern[p] {
+		case '\\':
+			if p+1 >= len(pattern) {
+				return fmt.Errorf("invalid glob %q: trailing backslash", pattern)
+			}
+
+			p++
+		case '[':
+			end, err := classEnd(pattern, p)
+
+			if err != nil {
+				return fmt.Errorf("invalid glob %q: %s", pattern, err)
+			}
+
+			p = end
+		}
+	}
+
+	return nil
+}
+
+// classEnd returns the index of the ] that closes the character class
+// starting at pattern[start]
+func classEnd(pattern string, start int) (int, error) {
+	p := start + 1
+
+	if p < len(pattern) && (pattern[p] == '!' || pattern[p] == '^') {
+		p++
+	}
+
+	// a ] directly after the opening bracket is a literal
+	if p < len(pattern) && pattern[p] == ']' {
+		p++
+	}
+
+	for ; p < len(pattern); p++ {
+		switch {
+		case pattern[p] == '\\':
+			p++
+		case pattern[p] == '[' && p+1 < len(pattern) && pattern[p+1] == ':':
+			end := strings.Index(pattern[p+2:], ":]")
+			close := strings.IndexByte(pattern[p+2:], ']')
+
+			if end < 0 || close < end {
+				// not a class name, the [ is a literal
+				continue
+			}
+
+			name := pattern[p+2 : p+2+end]
+
+			if _, ok := charClass(name, 'a', 0); !ok {
+				return 0, fmt.Errorf("unknown character class [:%s:]", name)
+			}
+
+			p += end + 3
+		case pattern[p] == ']':
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated character class at offset %d", start)
+}
+
+func wildmatch(pattern, text string, flags wmFlags) bool {
+	return dowild(pattern, text, 0, flags) == wmMatch
+}
+
+func isGlobSpecial(c byte) bool {
+	return c == '*' || c == '?' || c == '[' || c == '\\'
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// leadingPeriod reports whether text[ti] is a period that, with wmPeriod,
+// may not be matched by a wildcard
+func leadingPeriod(text string, ti int, flags wmFlags) bool {
+	if flags&wmPeriod == 0 || ti >= len(text) || text[ti] != '.' {
+		return false
+	}
+
+	return ti == 0 || (flags&wmPathname != 0 && text[ti-1] == '/')
+}
+
+// dowild matches pat against text[ti:].  As in the C implementation, pat
+// is re-sliced on recursion while text keeps its start so that leading
+// periods can be detected
+func dowild(pat, text string, ti int, flags wmFlags) int {
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+
+		return 0
+	}
+
+	fold := func(c byte) byte {
+		if flags&wmCaseFold != 0 {
+			return lower(c)
+		}
+
+		return c
+	}
+
+	for p := 0; p < len(pat); ti, p = ti+1, p+1 {
+		pch := fold(pat[p])
+
+		if ti >= len(text) && pch != '*' {
+			return wmAbortAll
+		}
+
+		tch := fold(at(text, ti))
+
+		switch pch {
+		case '\\':
+			if flags&wmNoEscape == 0 {
+				// literal match with the following character
+				p++
+				pch = fold(at(pat, p))
+			}
+
+			if tch != pch {
+				return wmNoMatch
+			}
+
+			continue
+		default:
+			if tch != pch {
+				return wmNoMatch
+			}
+
+			continue
+		case '?':
+			if flags&wmPathname != 0 && tch == '/' {
+				return wmNoMatch
+			}
+
+			if leadingPeriod(text, ti, flags) {
+				return wmNoMatch
+			}
+
+			continue
+		case '*':
+			var matchSlash bool
+
+			if p++; at(pat, p) == '*' {
+				prev := p - 2
+
+				for p++; at(pat, p) == '*'; p++ {
+				}
+
+				if flags&wmPathname == 0 {
+					// without wmPathname, * is the same as **
+					matchSlash = true
+				} else if (prev < 0 || pat[prev] == '/') &&
+					(p == len(pat) || pat[p] == '/' || (pat[p] == '\\' && at(pat, p+1) == '/')) {
+					// foo/**/bar matches both foo/bar and foo/a/bar, so
+					// first try matching nothing
+					if at(pat, p) == '/' && dowild(pat[p+1:], text, ti, flags) == wmMatch {
+						return wmMatch
+					}
+
+					matchSlash = true
+				}
+			} else {
+				matchSlash = flags&wmPathname == 0
+			}
+
+			if p == len(pat) {
+				// a trailing ** matches everything, a trailing * only
+				// matches if there are no more slashes
+				if !matchSlash && strings.IndexByte(text[ti:], '/') >= 0 {
+					return wmNoMatch
+				}
+
+				for i := ti; i < len(text); i++ {
+					if leadingPeriod(text, i, flags) {
+						return wmNoMatch
+					}
+				}
+
+				return wmMatch
+			} else if !matchSlash && pat[p] == '/' {
+				// a single * followed by a slash matches the next directory
+				slash := strings.IndexByte(text[ti:], '/')
+
+				if slash < 0 {
+					return wmNoMatch
+				}
+
+				if slash > 0 && leadingPeriod(text, ti, flags) {
+					return wmNoMatch
+				}
+
+				// the slash is consumed by the loop
+				ti += slash
+				break
+			}
+
+			for ti < len(text) {
+				// advance quickly when the * is followed by a literal,
+				// without looking past a slash if * cannot match it
+				if !isGlobSpecial(pat[p]) {
+					pch = fold(pat[p])
+
+					for ti < len(text) && (matchSlash || text[ti] != '/') {
+						tch = fold(text[ti])
+
+						if tch == pch {
+							break
+						}
+
+						if leadingPeriod(text, ti, flags) {
+							return wmNoMatch
+						}
+
+						ti++
+					}
+
+					if ti >= len(text) || tch != pch {
+						return wmNoMatch
+					}
+				}
+
+				if m := dowild(pat[p:], text, ti, flags); m != wmNoMatch {
+					if !matchSlash || m != wmAbortToStarStar {
+						return m
+					}
+				} else if !matchSlash && tch == '/' {
+					return wmAbortToStarStar
+				}
+
+				if leadingPeriod(text, ti, flags) {
+					return wmNoMatch
+				}
+
+				ti++
+				tch = fold(at(text, ti))
+			}
+
+			return wmAbortAll
+		case '[':
+			p++
+			pch = at(pat, p)
+
+			if pch == '^' {
+				pch = '!'
+			}
+
+			negated := pch == '!'
+
+			if negated {
+				p++
+				pch = at(pat, p)
+			}
+
+			var (
+				prev    byte
+				matched bool
+			)
+
+			for {
+				if p >= len(pat) {
+					return wmAbortAll
+				}
+
+				switch {
+				case pch == '\\' && flags&wmNoEscape == 0:
+					p++
+
+					if p >= len(pat) {
+						return wmAbortAll
+					}
+
+					pch = pat[p]
+
+					if tch == fold(pch) {
+						matched = true
+					}
+				case pch == '-' && prev != 0 && p+1 < len(pat) && pat[p+1] != ']':
+					p++
+					pch = pat[p]
+
+					if pch == '\\' && flags&wmNoEscape == 0 {
+						p++
+
+						if p >= len(pat) {
+							return wmAbortAll
+						}
+
+						pch = pat[p]
+					}
+
+					if tch <= pch && tch >= prev {
+						matched = true
+					} else if flags&wmCaseFold != 0 && isLower(tch) {
+						if up := tch - 'a' + 'A'; up <= pch && up >= prev {
+							matched = true
+						}
+					}
+
+					// makes prev 0 so that a following - is a literal
+					pch = 0
+				case pch == '[' && at(pat, p+1) == ':':
+					s := p + 2
+
+					for p = s; p < len(pat) && pat[p] != ']'; p++ {
+					}
+
+					if p >= len(pat) {
+						return wmAbortAll
+					}
+
+					if p-s-1 < 0 || pat[p-1] != ':' {
+						// no closing :], treat the [ as a literal
+						p = s - 2
+						pch = '['
+
+						if tch == pch {
+							matched = true
+						}
+
+						break
+					}
+
+					ok, valid := charClass(pat[s:p-1], tch, flags)
+
+					if !valid {
+						return wmAbortAll
+					}
+
+					if ok {
+						matched = true
+					}
+
+					pch = 0
+				default:
+					if tch == fold(pch) {
+						matched = true
+					}
+				}
+
+				prev = pch
+				p++
+				pch = at(pat, p)
+
+				if pch == ']' {
+					break
+				}
+			}
+
+			if matched == negated || (flags&wmPathname != 0 && tch == '/') {
+				return wmNoMatch
+			}
+
+			if leadingPeriod(text, ti, flags) {
+				return wmNoMatch
+			}
+
+			continue
+		}
+	}
+
+	if ti < len(text) {
+		return wmNoMatch
+	}
+
+	return wmMatch
+}
+
+// charClass reports whether c is in the named posix character class.
+// valid is false for unknown class names
+func charClass(name string, c byte, flags wmFlags) (ok bool, valid bool) {
+	switch name {
+	case "alnum":
+		return isLower(c) || isUpper(c) || isDigit(c), true
+	case "alpha":
+		return isLower(c) || isUpper(c), true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit(c), true
+	case "graph":
+		return c > 0x20 && c < 0x7f, true
+	case "lower":
+		return isLower(c) || (flags&wmCaseFold != 0 && isUpper(c)), true
+	case "print":
+		return c >= 0x20 && c < 0x7f, true
+	case "punct":
+		return c > 0x20 && c < 0x7f && !isLower(c) && !isUpper(c) && !isDigit(c), true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return isUpper(c) || (flags&wmCaseFold != 0 && isLower(c)), true
+	case "xdigit":
+		return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F'), true
+	}
+
+	return false, false
+}
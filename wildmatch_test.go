@@ -0,0 +1,245 @@
+package kovacs
+
+import "testing"
+
+// vectors ported from the wildmatch tests that watchman shares with git.
+// Each is evaluated with wmPathname, the flags used for wholename matches
+var wildmatchTests = []struct {
+	expected bool
+	text     string
+	pattern  string
+}{
+	{true, "foo", "foo"},
+	{false, "foo", "bar"},
+	{true, "", ""},
+	{true, "foo", "???"},
+	{false, "foo", "??"},
+	{true, "foo", "*"},
+	{true, "foo", "f*"},
+	{false, "foo", "*f"},
+	{true, "foo", "*foo*"},
+	{true, "foobar", "*ob*a*r*"},
+	{true, "aaaaaaabababab", "*ab"},
+	{true, "foo*", `foo\*`},
+	{false, "foobar", `foo\*bar`},
+	{true, `f\oo`, `f\\oo`},
+	{true, "ball", "*[al]?"},
+	{false, "ten", "[ten]"},
+	{true, "ten", "**[!te]"},
+	{false, "ten", "**[!ten]"},
+	{true, "ten", "t[a-g]n"},
+	{false, "ten", "t[!a-g]n"},
+	{true, "ton", "t[!a-g]n"},
+	{true, "ton", "t[^a-g]n"},
+	{true, "a]b", "a[]]b"},
+	{true, "a-b", "a[]-]b"},
+	{true, "a]b", "a[]-]b"},
+	{false, "aab", "a[]-]b"},
+	{true, "aab", "a[]a-]b"},
+	{true, "]", "]"},
+
+	// slash handling
+	{false, "foo/baz/bar", "foo*bar"},
+	{false, "foo/baz/bar", "foo**bar"},
+	{true, "foobazbar", "foo**bar"},
+	{true, "foo/baz/bar", "foo/**/bar"},
+	{true, "foo/baz/bar", "foo/**/**/bar"},
+	{true, "foo/b/a/z/bar", "foo/**/bar"},
+	{true, "foo/b/a/z/bar", "foo/**/**/bar"},
+	{true, "foo/bar", "foo/**/bar"},
+	{true, "foo/bar", "foo/**/**/bar"},
+	{false, "foo/bar", "foo?bar"},
+	{false, "foo/bar", "foo[/]bar"},
+	{false, "foo/bar", "foo[^a-z]bar"},
+	{false, "foo/bar", "f[^eiu][^eiu][^eiu][^eiu][^eiu]r"},
+	{true, "foo-bar", "f[^eiu][^eiu][^eiu][^eiu][^eiu]r"},
+	{true, "foo", "**/foo"},
+	{true, "XXX/foo", "**/foo"},
+	{true, "bar/baz/foo", "**/foo"},
+	{false, "bar/baz/foo", "*/foo"},
+	{false, "foo/bar/baz", "**/bar*"},
+	{true, "deep/foo/bar/baz", "**/bar/*"},
+	{false, "deep/foo/bar/baz/", "**/bar/*"},
+	{true, "deep/foo/bar/baz/", "**/bar/**"},
+	{false, "deep/foo/bar", "**/bar/*"},
+	{true, "deep/foo/bar/", "**/bar/**"},
+	{false, "foo/bar/baz", "**/bar**"},
+	{true, "foo/bar/baz/x", "*/bar/**"},
+	{false, "deep/foo/bar/baz/x", "*/bar/**"},
+	{true, "deep/foo/bar/baz/x", "**/bar/*/*"},
+
+	// various additional tests
+	{false, "acrt", "a[c-c]st"},
+	{true, "acrt", "a[c-c]rt"},
+	{false, "]", "[!]-]"},
+	{true, "a", "[!]-]"},
+	{false, "", `\`},
+	{false, `\`, `\`},
+	{false, `XXX/\`, `*/\`},
+	{true, `XXX/\`, `*/\\`},
+	{true, "@foo", "@foo"},
+	{false, "foo", "@foo"},
+	{true, "[ab]", `\[ab]`},
+	{true, "[ab]", "[[]ab]"},
+	{true, "[ab]", "[[:]ab]"},
+	{false, "[ab]", "[[::]ab]"},
+	{true, "[ab]", "[[:digit]ab]"},
+	{true, "[ab]", `[\[:]ab]`},
+	{true, "?a?b", `\??\?b`},
+	{true, "abc", `\a\b\c`},
+	{false, "foo", ""},
+	{true, "foo/bar/baz/to", "**/t[o]"},
+
+	// character classes
+	{true, "a1B", "[[:alpha:]][[:digit:]][[:upper:]]"},
+	{false, "a", "[[:digit:][:upper:][:space:]]"},
+	{true, "A", "[[:digit:][:upper:][:space:]]"},
+	{true, "1", "[[:digit:][:upper:][:space:]]"},
+	{false, "1", "[[:digit:][:upper:][:spaci:]]"},
+	{true, " ", "[[:digit:][:upper:][:space:]]"},
+	{false, ".", "[[:digit:][:upper:][:space:]]"},
+	{true, ".", "[[:digit:][:punct:][:space:]]"},
+	{true, "5", "[[:xdigit:]]"},
+	{true, "f", "[[:xdigit:]]"},
+	{true, "D", "[[:xdigit:]]"},
+	{true, "5", "[a-c[:digit:]x-z]"},
+	{true, "b", "[a-c[:digit:]x-z]"},
+	{true, "y", "[a-c[:digit:]x-z]"},
+	{false, "q", "[a-c[:digit:]x-z]"},
+
+	// additional ranges
+	{true, "]", `[\\-^]`},
+	{false, "[", `[\\-^]`},
+	{true, "-", `[\-_]`},
+	{true, "]", `[\]]`},
+	{false, `\]`, `[\]]`},
+	{false, `\`, `[\]]`},
+	{false, "ab", "a[]b"},
+	{false, "a[]b", "a[]b"},
+	{false, "ab[", "ab["},
+	{false, "ab", "[!"},
+	{false, "ab", "[-"},
+	{true, "-", "[-]"},
+	{false, "-", "[a-"},
+	{false, "-", "[!a-"},
+	{true, "-", "[--A]"},
+	{true, "5", "[--A]"},
+	{true, " ", "[ --]"},
+	{true, "$", "[ --]"},
+	{true, "-", "[ --]"},
+	{false, "0", "[ --]"},
+	{true, "-", "[---]"},
+	{true, "-", "[------]"},
+	{false, "j", "[a-e-n]"},
+	{true, "-", "[a-e-n]"},
+	{true, "a", "[!------]"},
+	{false, "[", "[]-a]"},
+	{true, "^", "[]-a]"},
+	{false, "^", "[!]-a]"},
+	{true, "[", "[!]-a]"},
+	{true, "^", "[a^bc]"},
+	{true, "-b]", "[a-]b]"},
+	{false, `\`, `[\]`},
+	{true, `\`, `[\\]`},
+	{false, `\`, `[!\\]`},
+	{true, "G", `[A-\\]`},
+	{false, "aaabbb", "b*a"},
+	{false, "aabcaa", "*ba*"},
+	{true, ",", "[,]"},
+	{true, ",", `[\\,]`},
+	{true, `\`, `[\\,]`},
+	{true, "-", "[,-.]"},
+	{false, "+", "[,-.]"},
+	{false, "-.]", "[,-.]"},
+	{true, "2", `[\1-\3]`},
+	{true, "3", `[\1-\3]`},
+	{false, "4", `[\1-\3]`},
+	{true, `\`, `[[-\]]`},
+	{true, "[", `[[-\]]`},
+	{true, "]", `[[-\]]`},
+	{false, "-", `[[-\]]`},
+
+	// recursion
+	{true, "-adobe-courier-bold-o-normal--12-120-75-75-m-70-iso8859-1", "-*-*-*-*-*-*-12-*-*-*-m-*-*-*"},
+	{false, "-adobe-courier-bold-o-normal--12-120-75-75-X-70-iso8859-1", "-*-*-*-*-*-*-12-*-*-*-m-*-*-*"},
+	{false, "-adobe-courier-bold-o-normal--12-120-75-75-/-70-iso8859-1", "-*-*-*-*-*-*-12-*-*-*-m-*-*-*"},
+	{true, "XXX/adobe/courier/bold/o/normal//12/120/75/75/m/70/iso8859/1", "XXX/*/*/*/*/*/*/12/*/*/*/m/*/*/*"},
+	{false, "XXX/adobe/courier/bold/o/normal//12/120/75/75/X/70/iso8859/1", "XXX/*/*/*/*/*/*/12/*/*/*/m/*/*/*"},
+	{true, "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txt", "**/*a*b*g*n*t"},
+	{false, "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txtz", "**/*a*b*g*n*t"},
+	{false, "foo", "*/*/*"},
+	{false, "foo/bar", "*/*/*"},
+	{true, "foo/bba/arr", "*/*/*"},
+	{false, "foo/bb/aa/rr", "*/*/*"},
+	{true, "foo/bb/aa/rr", "**/**/**"},
+	{true, "abcXdefXghi", "*X*i"},
+	{false, "ab/cXd/efXg/hi", "*X*i"},
+	{true, "ab/cXd/efXg/hi", "*/*X*/*/*i"},
+	{true, "ab/cXd/efXg/hi", "**/*X*/**/*i"},
+}
+
+func TestWildmatch(t *testing.T) {
+	for _, test := range wildmatchTests {
+		ok := wildmatch(test.pattern, test.text, wmPathname)
+		assert(t, ok == test.expected, "wildmatch(%q, %q): expected %t, found %t", test.pattern, test.text, test.expected, ok)
+	}
+}
+
+func TestWildmatch_Flags(t *testing.T) {
+	tests := []struct {
+		expected bool
+		text     string
+		pattern  string
+		flags    wmFlags
+	}{
+		// without wmPathname * matches slashes
+		{true, "foo/baz/bar", "foo*bar", 0},
+		{true, "foo/bar", "foo?bar", 0},
+		{true, "bar/baz/foo", "*/foo", 0},
+
+		{true, "a", "[A-Z]", wmCaseFold},
+		{true, "A", "[a-z]", wmCaseFold},
+		{true, "A", "[B-Za]", wmCaseFold},
+		{true, "a", "[[:upper:]]", wmCaseFold},
+		{true, "FOO.go", "*.GO", wmCaseFold},
+		{false, "FOO.go", "*.GO", 0},
+
+		{false, ".foo", "*", wmPeriod},
+		{true, ".foo", ".*", wmPeriod},
+		{false, ".foo", "?foo", wmPeriod},
+		{false, ".foo", "[.]foo", wmPeriod},
+		{true, "a.foo", "a*", wmPeriod},
+		{true, ".foo", "*", 0},
+		{false, "a/.b", "a/*", wmPathname | wmPeriod},
+		{true, "a/.b", "a/.*", wmPathname | wmPeriod},
+		{false, "a/.b/c", "**", wmPathname | wmPeriod},
+		{false, "a/.b/c", "**/c", wmPathname | wmPeriod},
+		{true, "a/.b/c", "**/c", wmPathname},
+
+		{true, `a\b`, `a\b`, wmNoEscape},
+		{false, "ab", `a\b`, wmNoEscape},
+		{true, `\`, `[\]`, wmNoEscape},
+	}
+
+	for _, test := range tests {
+		ok := wildmatch(test.pattern, test.text, test.flags)
+		assert(t, ok == test.expected, "wildmatch(%q, %q, %d): expected %t, found %t", test.pattern, test.text, test.flags, test.expected, ok)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	matches, err := Glob("**/*.go", []string{"a.go", "src/b.go", "src/.git/c.go", "d.txt"})
+
+	assert(t, err == nil, "unexpected err: %s", err)
+	assert(t, len(matches) == 2 && matches[0] == "a.go" && matches[1] == "src/b.go", "unexpected matches %v", matches)
+
+	for _, pattern := range []string{"[abc", `foo\`, "[[:bogus:]]"} {
+		err := ValidateGlob(pattern)
+		assert(t, err != nil, "expected %q to be invalid", pattern)
+	}
+
+	for _, pattern := range []string{"[]]", "[[:alpha:]]", "[[:]ab]", `\[`} {
+		err := ValidateGlob(pattern)
+		assert(t, err == nil, "expected %q to be valid, found %s", pattern, err)
+	}
+}